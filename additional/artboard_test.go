@@ -13,7 +13,7 @@ func Test_NewArtboard_1(t *testing.T) {
 	artboard, err := NewArtboard(data)
 	require.NoError(t, err)
 	assert.Equal(t, &Artboard{
-		Text: "iPhone 6\x00",
+		Name: "iPhone 6\x00",
 		Type: 1,
 		Color: &ArtboardColor{
 			Red:   255,
@@ -35,7 +35,7 @@ func Test_NewArtboard_2(t *testing.T) {
 	artboard, err := NewArtboard(data)
 	require.NoError(t, err)
 	assert.Equal(t, &Artboard{
-		Text: "\x00",
+		Name: "\x00",
 		Type: 1,
 		Color: &ArtboardColor{
 			Red:   255,
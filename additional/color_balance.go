@@ -0,0 +1,33 @@
+package additional
+
+import "github.com/yu-ichiko/go-psd/util"
+
+// ColorBalanceSetting is the color balance of a tonal range. The values range -100 to 100.
+type ColorBalanceSetting struct {
+	CyanRed      int
+	MagentaGreen int
+	YellowBlue   int
+}
+
+type ColorBalance struct {
+	Shadows            ColorBalanceSetting
+	Midtones           ColorBalanceSetting
+	Highlights         ColorBalanceSetting
+	PreserveLuminosity bool
+}
+
+func NewColorBalance(buf []byte) (*ColorBalance, error) {
+	reader := util.NewReader(buf)
+	cb := &ColorBalance{}
+	for _, s := range []*ColorBalanceSetting{&cb.Shadows, &cb.Midtones, &cb.Highlights} {
+		if err := readShorts(reader, &s.CyanRed, &s.MagentaGreen, &s.YellowBlue); err != nil {
+			return nil, err
+		}
+	}
+	preserve, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	cb.PreserveLuminosity = preserve > 0
+	return cb, nil
+}
@@ -0,0 +1,27 @@
+package additional
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewColorBalance(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, []int16{-10, 20, -30, 0, 0, 100, -100, 5, 0})
+	buf.Write([]byte{1, 0})
+
+	cb, err := NewColorBalance(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, &ColorBalance{
+		Shadows:            ColorBalanceSetting{CyanRed: -10, MagentaGreen: 20, YellowBlue: -30},
+		Midtones:           ColorBalanceSetting{YellowBlue: 100},
+		Highlights:         ColorBalanceSetting{CyanRed: -100, MagentaGreen: 5},
+		PreserveLuminosity: true,
+	}, cb)
+
+	_, err = NewColorBalance(buf.Bytes()[:10])
+	require.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package additional
+
+import (
+	"errors"
+	"github.com/yu-ichiko/go-psd/util"
+)
+
+// CurvesPoint is a point of a curve. The values range 0 to 255.
+type CurvesPoint struct {
+	Output int
+	Input  int
+}
+
+// Curve is the curve of a channel, where the channel 0 is the composite.
+// Map based curves have the 256 output values in Map instead of Points.
+type Curve struct {
+	Channel int
+	Points  []CurvesPoint
+	Map     []byte
+}
+
+type Curves struct {
+	Version int
+	IsMap   bool
+	Curves  []Curve
+}
+
+func NewCurves(buf []byte) (*Curves, error) {
+	reader := util.NewReader(buf)
+	isMap, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	version, err := reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	if version != 1 && version != 4 {
+		return nil, errors.New("invalid Curves version")
+	}
+	curves := &Curves{Version: int(version), IsMap: isMap == 1}
+
+	// bit field of the channels having a curve
+	channels, err := reader.ReadUInt32()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < 32; i++ {
+		if channels&(1<<uint(i)) == 0 {
+			continue
+		}
+		curve, err := readCurve(reader, i, curves.IsMap)
+		if err != nil {
+			return nil, err
+		}
+		curves.Curves = append(curves.Curves, curve)
+	}
+
+	// the 'Crv ' block repeats the curves with their channel index
+	if reader.Len() < 4+2+4 {
+		return curves, nil
+	}
+	sig, err := reader.ReadString(4)
+	if err != nil {
+		return nil, err
+	}
+	if sig != "Crv " {
+		return nil, errors.New("invalid Curves signature")
+	}
+	version, err = reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	if version != 4 {
+		return nil, errors.New("invalid Curves version")
+	}
+	count, err := reader.ReadCount(4)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Curve, 0, count)
+	for i := 0; i < count; i++ {
+		channel, err := reader.ReadUInt16()
+		if err != nil {
+			return nil, err
+		}
+		curve, err := readCurve(reader, int(channel), curves.IsMap)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, curve)
+	}
+	curves.Curves = list
+	return curves, nil
+}
+
+func readCurve(reader *util.Reader, channel int, isMap bool) (Curve, error) {
+	curve := Curve{Channel: channel}
+	if isMap {
+		m, err := reader.ReadBytes(256)
+		if err != nil {
+			return curve, err
+		}
+		curve.Map = m
+		return curve, nil
+	}
+
+	count, err := reader.ReadUInt16()
+	if err != nil {
+		return curve, err
+	}
+	if int(count)*4 > reader.Len() {
+		return curve, errors.New("invalid Curves length")
+	}
+	curve.Points = make([]CurvesPoint, count)
+	for i := range curve.Points {
+		output, err := reader.ReadUInt16()
+		if err != nil {
+			return curve, err
+		}
+		input, err := reader.ReadUInt16()
+		if err != nil {
+			return curve, err
+		}
+		curve.Points[i] = CurvesPoint{Output: int(output), Input: int(input)}
+	}
+	return curve, nil
+}
@@ -0,0 +1,59 @@
+package additional
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewCurves(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(0)
+	binary.Write(buf, binary.BigEndian, uint16(1))
+	binary.Write(buf, binary.BigEndian, uint32(0x5)) // composite and green
+	binary.Write(buf, binary.BigEndian, []uint16{2, 0, 0, 255, 255})
+	binary.Write(buf, binary.BigEndian, []uint16{3, 0, 0, 140, 128, 255, 255})
+
+	curves, err := NewCurves(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, 1, curves.Version)
+	assert.False(t, curves.IsMap)
+	assert.Equal(t, []Curve{
+		{Channel: 0, Points: []CurvesPoint{{0, 0}, {255, 255}}},
+		{Channel: 2, Points: []CurvesPoint{{0, 0}, {140, 128}, {255, 255}}},
+	}, curves.Curves)
+
+	buf.WriteString("Crv ")
+	binary.Write(buf, binary.BigEndian, uint16(4))
+	binary.Write(buf, binary.BigEndian, uint32(1))
+	binary.Write(buf, binary.BigEndian, []uint16{33, 2, 0, 10, 255, 245})
+
+	curves, err = NewCurves(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, []Curve{
+		{Channel: 33, Points: []CurvesPoint{{0, 10}, {255, 245}}},
+	}, curves.Curves)
+
+	// map based
+	m := make([]byte, 256)
+	for i := range m {
+		m[i] = byte(255 - i)
+	}
+	buf.Reset()
+	buf.WriteByte(1)
+	binary.Write(buf, binary.BigEndian, uint16(4))
+	binary.Write(buf, binary.BigEndian, uint32(0x1))
+	buf.Write(m)
+
+	curves, err = NewCurves(buf.Bytes())
+	require.NoError(t, err)
+	assert.True(t, curves.IsMap)
+	assert.Equal(t, []Curve{{Channel: 0, Map: m}}, curves.Curves)
+
+	_, err = NewCurves(buf.Bytes()[:100])
+	require.Error(t, err)
+	_, err = NewCurves([]byte{0, 0, 2, 0, 0, 0, 0})
+	require.Error(t, err)
+}
@@ -2,24 +2,18 @@ package additional
 
 import (
 	"errors"
-	"github.com/k0kubun/pp"
 	"github.com/yu-ichiko/go-psd/descriptor"
 	"github.com/yu-ichiko/go-psd/util"
 )
 
-func NewGradientFill(buf []byte) error {
+func NewGradientFill(buf []byte) (*descriptor.Descriptor, error) {
 	reader := util.NewReader(buf)
 	version, err := reader.ReadInt32()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if version != 16 {
-		return errors.New("")
+		return nil, errors.New("invalid GradientFill version")
 	}
-	desc, err := descriptor.Parse(reader)
-	if err != nil {
-		return err
-	}
-	pp.Println(desc)
-	return nil
+	return descriptor.Parse(reader)
 }
@@ -0,0 +1,85 @@
+package additional
+
+import (
+	"errors"
+	"github.com/yu-ichiko/go-psd/util"
+)
+
+// HueSaturationSetting is the hue (-180...180), saturation and lightness (-100...100) setting.
+type HueSaturationSetting struct {
+	Hue        int
+	Saturation int
+	Lightness  int
+}
+
+// HueSaturationRange is the setting of a color range. Range holds the
+// four boundaries of the range on the hue wheel.
+type HueSaturationRange struct {
+	Range [4]int
+	HueSaturationSetting
+}
+
+// HueSaturation is the hue/saturation adjustment. Version is 1 for the
+// Photoshop 4.0 'hue ' block and 2 for 'hue2', both have the same layout.
+type HueSaturation struct {
+	Version  int
+	Colorize bool
+	// Colorization is used instead of the others when Colorize is set
+	Colorization HueSaturationSetting
+	Master       HueSaturationSetting
+	// reds, yellows, greens, cyans, blues and magentas
+	Ranges [6]HueSaturationRange
+}
+
+func NewHueSaturation(buf []byte) (*HueSaturation, error) {
+	reader := util.NewReader(buf)
+	version, err := reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	if version != 1 && version != 2 {
+		return nil, errors.New("invalid HueSaturation version")
+	}
+	colorize, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	// padding
+	if err := reader.Skip(1); err != nil {
+		return nil, err
+	}
+
+	hs := &HueSaturation{Version: int(version), Colorize: colorize == 1}
+	if err := readHueSaturationSetting(reader, &hs.Colorization); err != nil {
+		return nil, err
+	}
+	if err := readHueSaturationSetting(reader, &hs.Master); err != nil {
+		return nil, err
+	}
+	for i := range hs.Ranges {
+		r := &hs.Ranges[i]
+		if err := readShorts(reader, &r.Range[0], &r.Range[1], &r.Range[2], &r.Range[3]); err != nil {
+			return nil, err
+		}
+		if err := readHueSaturationSetting(reader, &r.HueSaturationSetting); err != nil {
+			return nil, err
+		}
+	}
+	return hs, nil
+}
+
+func readHueSaturationSetting(reader *util.Reader, s *HueSaturationSetting) error {
+	return readShorts(reader, &s.Hue, &s.Saturation, &s.Lightness)
+}
+
+// readShorts reads the signed 2 byte values in order.
+func readShorts(reader *util.Reader, values ...*int) error {
+	for _, v := range values {
+		n, err := reader.ReadInt16()
+		if err != nil {
+			return err
+		}
+		*v = int(n)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package additional
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func testHueSaturation(version int) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint16(version))
+	buf.Write([]byte{1, 0})
+	binary.Write(buf, binary.BigEndian, []int16{30, 25, 0})
+	binary.Write(buf, binary.BigEndian, []int16{-180, 50, -100})
+	for i := 0; i < 6; i++ {
+		binary.Write(buf, binary.BigEndian, []int16{315, 345, 15, 45})
+		binary.Write(buf, binary.BigEndian, []int16{int16(i), 0, -int16(i)})
+	}
+	return buf.Bytes()
+}
+
+func TestNewHueSaturation(t *testing.T) {
+	buf := testHueSaturation(2)
+	hs, err := NewHueSaturation(buf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, hs.Version)
+	assert.True(t, hs.Colorize)
+	assert.Equal(t, HueSaturationSetting{Hue: 30, Saturation: 25}, hs.Colorization)
+	assert.Equal(t, HueSaturationSetting{Hue: -180, Saturation: 50, Lightness: -100}, hs.Master)
+	assert.Equal(t, HueSaturationRange{
+		Range:                [4]int{315, 345, 15, 45},
+		HueSaturationSetting: HueSaturationSetting{Hue: 5, Lightness: -5},
+	}, hs.Ranges[5])
+
+	_, err = NewHueSaturation(buf[:90])
+	require.Error(t, err)
+
+	// the Photoshop 4.0 'hue ' block
+	parser, ok := Lookup("hue ")
+	require.True(t, ok)
+	v, err := parser(testHueSaturation(1))
+	require.NoError(t, err)
+	hs, ok = v.(*HueSaturation)
+	require.True(t, ok)
+	assert.Equal(t, 1, hs.Version)
+	assert.Equal(t, HueSaturationSetting{Hue: -180, Saturation: 50, Lightness: -100}, hs.Master)
+
+	_, err = NewHueSaturation(testHueSaturation(3))
+	require.Error(t, err)
+}
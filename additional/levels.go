@@ -0,0 +1,97 @@
+package additional
+
+import (
+	"errors"
+	"github.com/yu-ichiko/go-psd/util"
+)
+
+// levelsLegacyCount is the number of level records preceding the 'Lvls' block.
+const levelsLegacyCount = 29
+
+// LevelsRecord is the levels setting of a channel.
+// The first record is the composite, followed by the channels of the document.
+type LevelsRecord struct {
+	InputFloor    int
+	InputCeiling  int
+	OutputFloor   int
+	OutputCeiling int
+	Gamma         float64
+}
+
+type Levels struct {
+	Version int
+	Records []LevelsRecord
+}
+
+func NewLevels(buf []byte) (*Levels, error) {
+	reader := util.NewReader(buf)
+	version, err := reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	if version != 2 {
+		return nil, errors.New("invalid Levels version")
+	}
+
+	levels := &Levels{Version: int(version)}
+	levels.Records, err = readLevelsRecords(reader, levelsLegacyCount)
+	if err != nil {
+		return nil, err
+	}
+
+	// the records of the channels above the legacy ones (Photoshop CS)
+	if reader.Len() < 4+2+2 {
+		return levels, nil
+	}
+	sig, err := reader.ReadString(4)
+	if err != nil {
+		return nil, err
+	}
+	if sig != "Lvls" {
+		return nil, errors.New("invalid Levels signature")
+	}
+	version, err = reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	if version != 3 {
+		return nil, errors.New("invalid Levels version")
+	}
+	count, err := reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	if count > levelsLegacyCount {
+		records, err := readLevelsRecords(reader, int(count)-levelsLegacyCount)
+		if err != nil {
+			return nil, err
+		}
+		levels.Records = append(levels.Records, records...)
+	}
+	return levels, nil
+}
+
+func readLevelsRecords(reader *util.Reader, count int) ([]LevelsRecord, error) {
+	if count*10 > reader.Len() {
+		return nil, errors.New("invalid Levels length")
+	}
+	records := make([]LevelsRecord, count)
+	for i := range records {
+		var values [5]uint16
+		for j := range values {
+			v, err := reader.ReadUInt16()
+			if err != nil {
+				return nil, err
+			}
+			values[j] = v
+		}
+		records[i] = LevelsRecord{
+			InputFloor:    int(values[0]),
+			InputCeiling:  int(values[1]),
+			OutputFloor:   int(values[2]),
+			OutputCeiling: int(values[3]),
+			Gamma:         float64(values[4]) / 100,
+		}
+	}
+	return records, nil
+}
@@ -0,0 +1,37 @@
+package additional
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewLevels(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint16(2))
+	for i := 0; i < 29; i++ {
+		binary.Write(buf, binary.BigEndian, []uint16{uint16(i), 255, 0, 255, 100})
+	}
+
+	levels, err := NewLevels(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, 2, levels.Version)
+	require.Len(t, levels.Records, 29)
+	assert.Equal(t, LevelsRecord{InputFloor: 1, InputCeiling: 255, OutputFloor: 0, OutputCeiling: 255, Gamma: 1}, levels.Records[1])
+
+	buf.WriteString("Lvls")
+	binary.Write(buf, binary.BigEndian, []uint16{3, 30})
+	binary.Write(buf, binary.BigEndian, []uint16{10, 240, 5, 250, 150})
+
+	levels, err = NewLevels(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, levels.Records, 30)
+	assert.Equal(t, LevelsRecord{InputFloor: 10, InputCeiling: 240, OutputFloor: 5, OutputCeiling: 250, Gamma: 1.5}, levels.Records[29])
+
+	_, err = NewLevels(buf.Bytes()[:100])
+	require.Error(t, err)
+	_, err = NewLevels([]byte{0, 1})
+	require.Error(t, err)
+}
@@ -14,7 +14,7 @@ type Metadata struct {
 
 func NewMetadataSetting(buf []byte) ([]Metadata, error) {
 	reader := util.NewReader(buf)
-	count, err := reader.ReadInt()
+	count, err := reader.ReadCount(12)
 	if err != nil {
 		return nil, err
 	}
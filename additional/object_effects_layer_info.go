@@ -6,26 +6,21 @@ import (
 	"github.com/yu-ichiko/go-psd/util"
 )
 
-func NewObjectEffectsLayerInfo(buf []byte) error {
+func NewObjectEffectsLayerInfo(buf []byte) (*descriptor.Descriptor, error) {
 	reader := util.NewReader(buf)
 	version, err := reader.ReadInt()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if version != 0 {
-		return errors.New("invalid Object effects version")
+		return nil, errors.New("invalid Object effects version")
 	}
 	version, err = reader.ReadInt()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if version != 16 {
-		return errors.New("invalid Object effects Descriptor version")
+		return nil, errors.New("invalid Object effects Descriptor version")
 	}
-	_, err = descriptor.Parse(reader)
-	if err != nil {
-		return err
-	}
-	//pp.Println(desc)
-	return nil
+	return descriptor.Parse(reader)
 }
@@ -0,0 +1,61 @@
+package additional
+
+import "sync"
+
+// Parser parses the data of an additional layer information block
+// into a typed value.
+type Parser func(buf []byte) (interface{}, error)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[string]Parser{}
+)
+
+// Register registers a parser for the 4 character additional layer information key.
+// Registering a key again replaces the previous parser, including the built-in ones.
+func Register(key string, parser Parser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	if parser == nil {
+		delete(parsers, key)
+		return
+	}
+	parsers[key] = parser
+}
+
+// Lookup returns the parser registered for key.
+func Lookup(key string) (Parser, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	parser, ok := parsers[key]
+	return parser, ok
+}
+
+func init() {
+	Register("lyid", func(buf []byte) (interface{}, error) { return NewLayerID(buf) })
+	Register("lnsr", func(buf []byte) (interface{}, error) { return NewLayerNameSource(buf) })
+	Register("luni", func(buf []byte) (interface{}, error) { return NewLayerName(buf) })
+	Register("artb", func(buf []byte) (interface{}, error) { return NewArtboard(buf) })
+	Register("fxrp", func(buf []byte) (interface{}, error) { return NewReferencePoint(buf) })
+	Register("GdFl", func(buf []byte) (interface{}, error) { return NewGradientFill(buf) })
+	Register("clbl", func(buf []byte) (interface{}, error) { return NewBlendClippingElements(buf) })
+	Register("infx", func(buf []byte) (interface{}, error) { return NewBlendInteriorElements(buf) })
+	Register("knko", func(buf []byte) (interface{}, error) { return NewKnockoutSetting(buf) })
+	Register("lspf", func(buf []byte) (interface{}, error) { return NewLocked(buf) })
+	Register("lclr", func(buf []byte) (interface{}, error) { return NewSheetColorSetting(buf) })
+	Register("shmd", func(buf []byte) (interface{}, error) { return NewMetadataSetting(buf) })
+	Register("SoCo", func(buf []byte) (interface{}, error) { return NewSolidColorSheetSetting(buf) })
+	Register("TySh", func(buf []byte) (interface{}, error) { return NewTypeToolObjectSetting(buf) })
+	Register("lsct", func(buf []byte) (interface{}, error) { return NewSectionDividerSetting(buf) })
+	Register("vmsk", func(buf []byte) (interface{}, error) { return NewVectorMaskSetting(buf) })
+	Register("vsms", func(buf []byte) (interface{}, error) { return NewVectorMaskSetting(buf) })
+	Register("lyvr", func(buf []byte) (interface{}, error) { return NewLayerVersion(buf) })
+	Register("lfx2", func(buf []byte) (interface{}, error) { return NewObjectEffectsLayerInfo(buf) })
+	Register("lrFX", func(buf []byte) (interface{}, error) { return NewEffectsLayer(buf) })
+	Register("levl", func(buf []byte) (interface{}, error) { return NewLevels(buf) })
+	Register("curv", func(buf []byte) (interface{}, error) { return NewCurves(buf) })
+	Register("hue2", func(buf []byte) (interface{}, error) { return NewHueSaturation(buf) })
+	Register("hue ", func(buf []byte) (interface{}, error) { return NewHueSaturation(buf) })
+	Register("blnc", func(buf []byte) (interface{}, error) { return NewColorBalance(buf) })
+	Register("selc", func(buf []byte) (interface{}, error) { return NewSelectiveColor(buf) })
+}
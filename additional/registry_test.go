@@ -0,0 +1,27 @@
+package additional
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	parser, ok := Lookup("lyid")
+	require.True(t, ok)
+	v, err := parser([]byte{0, 0, 2, 214})
+	require.NoError(t, err)
+	assert.Equal(t, 726, v)
+
+	_, ok = Lookup("tst!")
+	require.False(t, ok)
+
+	Register("tst!", func(buf []byte) (interface{}, error) { return string(buf), nil })
+	defer Register("tst!", nil)
+
+	parser, ok = Lookup("tst!")
+	require.True(t, ok)
+	v, err = parser([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", v)
+}
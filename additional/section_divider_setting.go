@@ -5,6 +5,14 @@ import (
 	"github.com/yu-ichiko/go-psd/util"
 )
 
+// section divider types
+const (
+	SectionDividerOther        = 0
+	SectionDividerOpenFolder   = 1
+	SectionDividerClosedFolder = 2
+	SectionDividerBounding     = 3
+)
+
 type SectionDivider struct {
 	Type      int
 	BlendMode string
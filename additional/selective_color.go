@@ -0,0 +1,50 @@
+package additional
+
+import (
+	"errors"
+	"github.com/yu-ichiko/go-psd/util"
+)
+
+// SelectiveColorPlate is the correction of a color. The values range -100 to 100.
+type SelectiveColorPlate struct {
+	Cyan    int
+	Magenta int
+	Yellow  int
+	Black   int
+}
+
+type SelectiveColor struct {
+	Version int
+	// Absolute tells if the corrections are absolute instead of relative
+	Absolute bool
+	// reds, yellows, greens, cyans, blues, magentas, whites, neutrals and blacks
+	Plates [9]SelectiveColorPlate
+}
+
+func NewSelectiveColor(buf []byte) (*SelectiveColor, error) {
+	reader := util.NewReader(buf)
+	version, err := reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	if version != 1 {
+		return nil, errors.New("invalid SelectiveColor version")
+	}
+	method, err := reader.ReadUInt16()
+	if err != nil {
+		return nil, err
+	}
+	sc := &SelectiveColor{Version: int(version), Absolute: method == 1}
+
+	// the first plate is reserved
+	if err := reader.Skip(4 * 2); err != nil {
+		return nil, err
+	}
+	for i := range sc.Plates {
+		p := &sc.Plates[i]
+		if err := readShorts(reader, &p.Cyan, &p.Magenta, &p.Yellow, &p.Black); err != nil {
+			return nil, err
+		}
+	}
+	return sc, nil
+}
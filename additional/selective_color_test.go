@@ -0,0 +1,30 @@
+package additional
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewSelectiveColor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, []uint16{1, 1})
+	binary.Write(buf, binary.BigEndian, []int16{-1, -1, -1, -1})
+	for i := 0; i < 9; i++ {
+		binary.Write(buf, binary.BigEndian, []int16{int16(i), -int16(i), 100, -100})
+	}
+
+	sc, err := NewSelectiveColor(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sc.Version)
+	assert.True(t, sc.Absolute)
+	assert.Equal(t, SelectiveColorPlate{Cyan: 0, Magenta: 0, Yellow: 100, Black: -100}, sc.Plates[0])
+	assert.Equal(t, SelectiveColorPlate{Cyan: 8, Magenta: -8, Yellow: 100, Black: -100}, sc.Plates[8])
+
+	_, err = NewSelectiveColor(buf.Bytes()[:40])
+	require.Error(t, err)
+	_, err = NewSelectiveColor([]byte{0, 2, 0, 0})
+	require.Error(t, err)
+}
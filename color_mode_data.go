@@ -1,6 +1,9 @@
 package psd
 
-import "errors"
+import (
+	"errors"
+	"image/color"
+)
 
 var (
 	ErrColorModeData = errors.New("psd: invalid color mode data")
@@ -8,4 +11,24 @@ var (
 
 type ColorModeData struct {
 	Data []byte
+
+	// Duotone is set for duotone images
+	Duotone *Duotone
+}
+
+// Palette returns the 256 colors of an indexed color image,
+// which are stored as planar red, green and blue tables.
+// The color at transparentIndex is fully transparent, -1 means none.
+func (c *ColorModeData) Palette(transparentIndex int) color.Palette {
+	if c == nil || len(c.Data) != 768 {
+		return nil
+	}
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.RGBA{R: c.Data[i], G: c.Data[i+256], B: c.Data[i+512], A: 0xff}
+	}
+	if transparentIndex >= 0 && transparentIndex < len(palette) {
+		palette[transparentIndex] = color.RGBA{}
+	}
+	return palette
 }
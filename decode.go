@@ -1,11 +1,17 @@
 package psd
 
 import (
+	"bufio"
 	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	psdImage "github.com/yu-ichiko/go-psd/image"
 	"github.com/yu-ichiko/go-psd/util"
 	"image"
+	"image/color"
 	"io"
 )
 
@@ -14,7 +20,91 @@ type decoder struct {
 	buf  []byte
 	read int
 
-	header *Header
+	// ctx cancels the decoding between the sections, layers and channels
+	ctx context.Context
+
+	// ra is set when the layer and composite images are decoded lazily
+	ra   io.ReaderAt
+	size int64
+
+	opts    *Options
+	header  *Header
+	palette color.Palette
+
+	// mergedAlpha tells if the composite image has a transparency channel
+	mergedAlpha bool
+
+	// position reported by FormatError
+	section string
+	layer   int
+	key     string
+
+	// problems skipped in lenient mode
+	warnings []error
+
+	limits     Limits
+	pixelBytes int64
+}
+
+// readChunk is the size above which blocks are read incrementally, so that
+// the memory allocated is bounded by the data actually present in the file.
+const readChunk = 1 << 20
+
+// batchSize bounds the compressed channel data buffered for the workers.
+var batchSize = 64 << 20
+
+func newDecoder(r io.Reader, opts *Options) *decoder {
+	if opts == nil {
+		opts = &Options{}
+	}
+	return &decoder{
+		r:      r,
+		ctx:    context.Background(),
+		opts:   opts,
+		header: &Header{},
+		layer:  -1,
+		limits: opts.Limits.withDefaults(),
+	}
+}
+
+// checkpoint reports the current position to Options.Progress
+// and returns the error of the context once it is canceled.
+func (dec *decoder) checkpoint() error {
+	if dec.opts.Progress != nil {
+		dec.opts.Progress(Progress{Section: dec.section, Layer: dec.layer, Read: int64(dec.read)})
+	}
+	return dec.ctx.Err()
+}
+
+// enter starts decoding the section.
+func (dec *decoder) enter(section string) error {
+	dec.section = section
+	return dec.checkpoint()
+}
+
+// checkRect validates the dimensions of an image or layer rectangle.
+func (dec *decoder) checkRect(rect image.Rectangle) error {
+	if rect.Dx() > dec.limits.MaxWidth || rect.Dy() > dec.limits.MaxHeight {
+		return limitError("%dx%d pixels", rect.Dx(), rect.Dy())
+	}
+	return nil
+}
+
+// addPixelBytes accounts n bytes of decoded pixel data.
+func (dec *decoder) addPixelBytes(n int) error {
+	dec.pixelBytes += int64(n)
+	if dec.pixelBytes > dec.limits.MaxPixelBytes {
+		return limitError("%d bytes of pixel data", dec.pixelBytes)
+	}
+	return nil
+}
+
+// checkLength validates a declared length against the end of the enclosing block.
+func (dec *decoder) checkLength(size, end int) error {
+	if size < 0 || size > end-dec.read {
+		return fmt.Errorf("psd: length %d exceeds the enclosing block", size)
+	}
+	return nil
 }
 
 func (dec *decoder) alloc(size int) {
@@ -33,6 +123,12 @@ func (dec *decoder) readBytes(size int, new ...bool) ([]byte, error) {
 	if size <= 0 {
 		return nil, nil
 	}
+	if size > dec.limits.MaxBlockSize {
+		return nil, limitError("block of %d bytes", size)
+	}
+	if size > readChunk {
+		return dec.readLarge(size)
+	}
 
 	dec.alloc(size)
 	l, err := io.ReadFull(dec.r, dec.buf[:size])
@@ -49,9 +145,87 @@ func (dec *decoder) readBytes(size int, new ...bool) ([]byte, error) {
 	return dec.buf[:size], nil
 }
 
-func (dec *decoder) seek(size int) (err error) {
-	_, err = dec.readBytes(size)
-	return
+// readLarge reads size bytes into a new slice growing with the data read.
+func (dec *decoder) readLarge(size int) ([]byte, error) {
+	data, n, err := readGrowing(dec.r, size)
+	dec.read += n
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readGrowing reads size bytes from r into a slice growing with the data read,
+// so that the memory allocated is bounded by the data actually present.
+func readGrowing(r io.Reader, size int) ([]byte, int, error) {
+	c := size
+	if c > readChunk {
+		c = readChunk
+	}
+	data := make([]byte, 0, c)
+	for len(data) < size {
+		n := size - len(data)
+		if n > readChunk {
+			n = readChunk
+		}
+		if cap(data)-len(data) < n {
+			c := 2 * cap(data)
+			if c > size {
+				c = size
+			}
+			grown := make([]byte, len(data), c)
+			copy(grown, data)
+			data = grown
+		}
+		l, err := io.ReadFull(r, data[len(data):len(data)+n])
+		if err != nil {
+			return nil, len(data) + l, err
+		}
+		data = data[:len(data)+n]
+	}
+	return data, len(data), nil
+}
+
+func (dec *decoder) seek(size int) error {
+	if size <= 0 {
+		return nil
+	}
+	if s, ok := dec.r.(io.Seeker); ok {
+		if _, err := s.Seek(int64(size), io.SeekCurrent); err != nil {
+			return err
+		}
+		dec.read += size
+		return nil
+	}
+	n, err := io.CopyN(io.Discard, dec.r, int64(size))
+	dec.read += int(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// tolerate records err as a warning and returns nil in lenient mode.
+// Exceeded limits and cancellation are always fatal.
+func (dec *decoder) tolerate(err error) error {
+	if err == nil || !dec.opts.Lenient || errors.Is(err, ErrLimitExceeded) || dec.ctx.Err() != nil {
+		return err
+	}
+	dec.warnings = append(dec.warnings, dec.formatError(err))
+	return nil
+}
+
+// seekTo skips to the absolute offset pos if it has not been reached yet.
+func (dec *decoder) seekTo(pos int) error {
+	return dec.seek(pos - dec.read)
+}
+
+func (dec *decoder) readSize() (int, error) {
+	buf, err := dec.readBytes(util.GetSize(dec.header.IsPSB()))
+	if err != nil {
+		return 0, err
+	}
+	return util.ReadSize(buf, 0, dec.header.IsPSB()), nil
 }
 
 func (dec *decoder) readPascalString() (string, int, error) {
@@ -62,7 +236,7 @@ func (dec *decoder) readPascalString() (string, int, error) {
 
 	size := int(buf[0])
 	if size <= 0 {
-		return "", 1, nil
+		return "", 0, nil
 	}
 
 	buf, err = dec.readBytes(size)
@@ -73,44 +247,6 @@ func (dec *decoder) readPascalString() (string, int, error) {
 	return string(dec.buf[:size]), size, nil
 }
 
-func (dec *decoder) readPackBits(l int) ([]byte, error) {
-
-	limit := dec.read + l
-	data := []byte{}
-
-	// decode
-	for dec.read < limit {
-		buf, err := dec.readBytes(1)
-		if err != nil {
-			return nil, err
-		}
-		run := int(int8(buf[0]))
-		if run < 0 {
-			run = 1 - run
-			buf, err = dec.readBytes(1, true)
-			if err != nil {
-				return nil, err
-			}
-			for run > 0 {
-				data = append(data, buf...)
-				run -= 1
-			}
-		} else {
-			run = 1 + run
-			for run > 0 {
-				buf, err = dec.readBytes(1, true)
-				if err != nil {
-					return nil, err
-				}
-				data = append(data, buf...)
-				run -= 1
-			}
-		}
-	}
-
-	return data, nil
-}
-
 func (dec *decoder) parseHeader() error {
 	buf, err := dec.readBytes(headerLen)
 	if err != nil {
@@ -120,11 +256,14 @@ func (dec *decoder) parseHeader() error {
 	// Signature
 	read := headerLens[0]
 	if !bytes.Equal(buf[:read], headerSig) {
-		return ErrHeaderVersion
+		return ErrHeaderFormat
 	}
 
-	// Version
+	// Version: 1 for PSD, 2 for PSB
 	dec.header.Version = int(util.ReadUint16(buf, read))
+	if dec.header.Version != 1 && dec.header.Version != 2 {
+		return ErrHeaderVersion
+	}
 	read += headerLens[1]
 
 	// Reserved: must be zero
@@ -150,7 +289,23 @@ func (dec *decoder) parseHeader() error {
 	dec.header.ColorMode = ColorMode(util.ReadUint16(buf, read))
 	read += headerLens[7]
 
-	return nil
+	switch {
+	case dec.header.Channels < 1 || dec.header.Channels > 56:
+		return ErrHeaderChannels
+	case dec.header.Channels < dec.header.ColorMode.Channels():
+		return ErrHeaderChannels
+	case dec.header.Height < 1:
+		return ErrHeaderHeight
+	case dec.header.Width < 1:
+		return ErrHeaderWidth
+	}
+	switch dec.header.Depth {
+	case 1, 8, 16, 32:
+	default:
+		return ErrHeaderDepth
+	}
+
+	return dec.checkRect(dec.header.Rect())
 }
 
 func (dec *decoder) parseColorModeData() (*ColorModeData, error) {
@@ -165,7 +320,9 @@ func (dec *decoder) parseColorModeData() (*ColorModeData, error) {
 	}
 
 	if dec.header.ColorMode == ColorModeIndexed && size != 768 {
-		return nil, ErrColorModeData
+		if err := dec.tolerate(ErrColorModeData); err != nil {
+			return nil, err
+		}
 	}
 
 	buf, err = dec.readBytes(size, true)
@@ -173,7 +330,15 @@ func (dec *decoder) parseColorModeData() (*ColorModeData, error) {
 		return nil, err
 	}
 
-	return &ColorModeData{Data: buf}, nil
+	data := &ColorModeData{Data: buf}
+	if dec.header.ColorMode == ColorModeDuotone {
+		data.Duotone, err = parseDuotone(buf)
+		if err := dec.tolerate(err); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
 }
 
 func (dec *decoder) parseImageResources() (blocks []*ImageResourceBlock, err error) {
@@ -186,15 +351,21 @@ func (dec *decoder) parseImageResources() (blocks []*ImageResourceBlock, err err
 	if size <= 0 {
 		return nil, nil
 	}
+	if dec.opts.SkipImageResources {
+		return nil, dec.seek(size)
+	}
 
-	l := dec.read + size
-	for dec.read < l {
+	end := dec.read + size
+	for dec.read < end {
 		buf, err = dec.readBytes(sigLen)
 		if err != nil {
 			return nil, err
 		}
 		if !bytes.Equal(buf[:], imgResSig) {
-			return nil, ErrImageResourceBlock
+			if err := dec.tolerate(ErrImageResourceBlock); err != nil {
+				return nil, err
+			}
+			return blocks, dec.seekTo(end)
 		}
 
 		block := &ImageResourceBlock{}
@@ -210,7 +381,8 @@ func (dec *decoder) parseImageResources() (blocks []*ImageResourceBlock, err err
 			return nil, err
 		}
 		block.Name = str
-		if l&1 != 0 {
+		// padded to make the size even
+		if (1+l)&1 != 0 {
 			err = dec.seek(1)
 			if err != nil {
 				return nil, err
@@ -222,6 +394,12 @@ func (dec *decoder) parseImageResources() (blocks []*ImageResourceBlock, err err
 			return nil, err
 		}
 		size = int(util.ReadUint32(buf, 0))
+		if err := dec.checkLength(size, end); err != nil {
+			if err := dec.tolerate(err); err != nil {
+				return nil, err
+			}
+			return blocks, dec.seekTo(end)
+		}
 		buf, err = dec.readBytes(size, true)
 		if err != nil {
 			return nil, err
@@ -242,92 +420,216 @@ func (dec *decoder) parseImageResources() (blocks []*ImageResourceBlock, err err
 }
 
 func (dec *decoder) parseLayerAndMaskInfo() ([]*Layer, *GlobalLayerMask, []*AdditionalInfo, error) {
-	s := dec.read
-
-	// TODO: adapt PSB
-	buf, err := dec.readBytes(sectionLen)
+	size, err := dec.readSize()
 	if err != nil {
 		return nil, nil, nil, err
 	}
-
-	size := int(util.ReadUint32(buf, 0))
 	if size <= 0 {
 		return nil, nil, nil, nil
 	}
 	pos := dec.read + size
 
 	// Layer Info
-	layers, err := dec.parseLayerInfo()
+	layers, err := dec.parseLayerInfo(pos)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	// padding
-	if dec.header.Depth == 8 {
-		if padding := (dec.read - s + 4 - size) & 3; padding > 0 {
-			err = dec.seek(4 - padding)
-			if err != nil {
-				return nil, nil, nil, err
-			}
+	// Global Layer Info
+	var globalMask *GlobalLayerMask
+	if dec.read < pos {
+		if err := dec.enter(sectionGlobalMask); err != nil {
+			return nil, nil, nil, err
+		}
+		globalMask, err = dec.parseGlobalLayerMask(pos)
+		if err := dec.tolerate(err); err != nil {
+			return nil, nil, nil, err
 		}
 	}
 
-	// Global Layer Info
-	globalMask, err := dec.parseGlobalLayerMask()
-	if err != nil {
+	// Additional Layer Info
+	if err := dec.enter(sectionAdditional); err != nil {
 		return nil, nil, nil, err
 	}
-
-	// Additional Layer Info
 	addInfos := []*AdditionalInfo{}
-	for dec.read < pos {
-		addInfo, err := dec.parseAdditionalLayerInfo()
-		if err != nil {
-			return nil, nil, nil, err
-		}
+	set := func(addInfo *AdditionalInfo) error {
 		addInfos = append(addInfos, addInfo)
+		return nil
+	}
+	// the layers of 16 and 32 bit documents are parsed from the stream like
+	// the layer info section, their block is kept without its data
+	stream := func(key string, end int) (bool, error) {
+		if !layerInfoKeys[key] || len(layers) > 0 {
+			return false, nil
+		}
+		var err error
+		layers, err = dec.parseLayerList(end)
+		return true, err
+	}
+	err = dec.parseAdditionalLayerInfos(pos, set, stream)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	return layers, globalMask, addInfos, nil
 }
 
-func (dec *decoder) parseLayerInfo() ([]*Layer, error) {
-	// TODO: PSB
-	buf, err := dec.readBytes(4) // Length of the layers info section
+func (dec *decoder) parseLayerInfo(end int) ([]*Layer, error) {
+	// Length of the layers info section, rounded up to a multiple of 2
+	size, err := dec.readSize()
 	if err != nil {
 		return nil, err
 	}
-	size := int(util.ReadUint32(buf, 0))
-	size = size * 1 // TODO: PSB is *2
+	if size <= 0 {
+		return nil, nil
+	}
+	if err := dec.checkLength(size, end); err != nil {
+		return nil, err
+	}
+	pos := dec.read + size
+
+	layers, err := dec.parseLayerList(pos)
+	if err != nil {
+		return nil, err
+	}
+
+	// padding
+	if err := dec.seekTo(pos); err != nil {
+		return nil, err
+	}
+
+	return layers, nil
+}
 
-	buf, err = dec.readBytes(2)
+// parseLayerList parses the layer count, the layer records and the channel image data.
+func (dec *decoder) parseLayerList(end int) ([]*Layer, error) {
+	buf, err := dec.readBytes(2)
 	if err != nil {
 		return nil, err
 	}
-	count := util.Abs(int(util.ReadInt16(buf, 0)))
+	// a negative count means the first alpha channel of the
+	// composite image is the merged transparency
+	count := int(util.ReadInt16(buf, 0))
+	if count < 0 {
+		dec.mergedAlpha = true
+		count = -count
+	}
+	if count > dec.limits.MaxLayers {
+		return nil, limitError("%d layers", count)
+	}
 
-	layers := make([]*Layer, count)
+	layers, err := dec.parseLayers(count, end)
+	if err != nil {
+		// the layers parsed so far are kept in lenient mode
+		if err := dec.tolerate(err); err != nil {
+			return nil, err
+		}
+	}
+	dec.layer = -1
+
+	return layers, nil
+}
+
+// parseLayers parses the layer records and the channel image data.
+func (dec *decoder) parseLayers(count, end int) ([]*Layer, error) {
+	layers := make([]*Layer, 0, count)
 	for i := 0; i < count; i++ {
-		layer, err := dec.parseLayerRecord()
+		dec.layer = i
+		if err := dec.checkpoint(); err != nil {
+			return layers, err
+		}
+		layer, err := dec.parseLayerRecord(end)
 		if err != nil {
-			return nil, err
+			return layers, err
 		}
 		layer.Index = i
-		layers[i] = layer
+		layers = append(layers, layer)
+	}
+
+	dec.layer = -1
+	size := 0
+	for _, layer := range layers {
+		for _, channel := range layer.Channels {
+			size += channel.Length
+		}
+	}
+	if err := dec.checkLength(size, end); err != nil {
+		return layers, err
 	}
 
-	// Channel image data
-	for i := range layers {
-		layers[i].Image, err = dec.parseChannelImageData(layers[i])
+	// Channel image data. The payloads are independent once read, so the workers
+	// decompress the channels of several layers together, in batches bounding the
+	// compressed data buffered. A single worker decodes each layer after reading it.
+	var (
+		batch    []*channelData
+		pending  []*Layer
+		buffered int
+	)
+	layerChannels := map[*Layer][]*channelData{}
+	flush := func() error {
+		if err := dec.decodeChannels(batch); err != nil {
+			return err
+		}
+		for _, layer := range pending {
+			dec.layer = layer.Index
+			if err := dec.setLayerImages(layer, layerChannels[layer]); err != nil {
+				return err
+			}
+			delete(layerChannels, layer)
+		}
+		batch, pending, buffered = nil, nil, 0
+		return nil
+	}
+	for _, layer := range layers {
+		dec.layer = layer.Index
+		if err := dec.checkpoint(); err != nil {
+			return layers, err
+		}
+		if !dec.opts.decodeLayerImage(layer) {
+			size := 0
+			for _, channel := range layer.Channels {
+				size += channel.Length
+			}
+			if err := dec.seek(size); err != nil {
+				return layers, err
+			}
+			continue
+		}
+		if dec.ra != nil {
+			dec.setLazyLayerImages(layer)
+			size := 0
+			for _, channel := range layer.Channels {
+				size += channel.Length
+			}
+			if err := dec.seek(size); err != nil {
+				return layers, err
+			}
+			continue
+		}
+		channels, err := dec.readChannelImageData(layer)
 		if err != nil {
-			return nil, err
+			return layers, err
+		}
+		layerChannels[layer] = channels
+		pending = append(pending, layer)
+		batch = append(batch, channels...)
+		for _, c := range channels {
+			buffered += len(c.buf)
+		}
+		if dec.opts.Workers <= 1 || buffered >= batchSize {
+			if err := flush(); err != nil {
+				return layers, err
+			}
 		}
 	}
+	if err := flush(); err != nil {
+		return layers, err
+	}
 
 	return layers, nil
 }
 
-func (dec *decoder) parseLayerRecord() (*Layer, error) {
+func (dec *decoder) parseLayerRecord(end int) (*Layer, error) {
 	buf, err := dec.readBytes(4*4 + 2)
 	if err != nil {
 		return nil, err
@@ -336,29 +638,37 @@ func (dec *decoder) parseLayerRecord() (*Layer, error) {
 	layer := newLayer()
 
 	layer.setRect(
-		int(util.ReadUint32(buf, 0)),
-		int(util.ReadUint32(buf, 4)),
-		int(util.ReadUint32(buf, 8)),
-		int(util.ReadUint32(buf, 12)),
+		int(util.ReadInt32(buf, 0)),
+		int(util.ReadInt32(buf, 4)),
+		int(util.ReadInt32(buf, 8)),
+		int(util.ReadInt32(buf, 12)),
 	)
 
 	size := int(util.ReadUint16(buf, 16))
 	layer.Channels = make([]*Channel, size)
 	for i := range layer.Channels {
 		channel := &Channel{}
-		// TODO: PSB (2+8)
-		buf, err := dec.readBytes(2 + 4)
+		buf, err := dec.readBytes(2 + util.GetSize(dec.header.IsPSB()))
 		if err != nil {
 			return nil, err
 		}
 		channel.ID = int(util.ReadInt16(buf, 0))
-		channel.Length = int(util.ReadUint32(buf, 2))
+		channel.Length = util.ReadSize(buf, 2, dec.header.IsPSB())
+		if channel.Length < compressionLen {
+			return nil, errors.New("psd: invalid channel length")
+		}
+		if err := dec.checkLength(channel.Length, end); err != nil {
+			return nil, err
+		}
 		layer.Channels[i] = channel
 	}
 
 	buf, err = dec.readBytes(16)
+	if err != nil {
+		return nil, err
+	}
 	if !bytes.Equal(buf[0:4], layerSig) {
-		return nil, ErrImageResourceBlock
+		return nil, ErrLayerSignature
 	}
 	layer.BlendModeKey = BlendModeKey(util.ReadString(buf, 4, 8))
 	layer.Opacity = int(buf[8])
@@ -378,41 +688,54 @@ func (dec *decoder) parseLayerRecord() (*Layer, error) {
 	layer.IrrelevantPixelData = (layer.Flags & (1 << 4)) == 0
 
 	size = int(util.ReadUint32(buf, 12))
+	if err := dec.checkLength(size, end); err != nil {
+		return nil, err
+	}
 	pos := size + dec.read
 
+	// the extra data ends at pos, so in lenient mode the record is kept
+	// with what could be parsed and the next record follows
+	if err := dec.parseLayerExtraData(layer, pos); err != nil {
+		if err := dec.tolerate(err); err != nil {
+			return nil, err
+		}
+	}
+
+	return layer, dec.seekTo(pos)
+}
+
+// parseLayerExtraData parses the mask, the blending ranges, the name
+// and the additional information of the layer record up to end.
+func (dec *decoder) parseLayerExtraData(layer *Layer, end int) error {
 	// Mask
-	layer.Mask, err = dec.parseMask()
-	if err != nil {
-		return nil, err
+	var err error
+	layer.Mask, err = dec.parseMask(end)
+	if err := dec.tolerate(err); err != nil {
+		return err
 	}
 
 	// Blending Ranges
-	layer.BlendingRanges, err = dec.parseBlendingRanges()
+	layer.BlendingRanges, err = dec.parseBlendingRanges(end)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Layer name (MBCS)
 	var l int
 	layer.LegacyName, l, err = dec.readPascalString()
+	if err != nil {
+		return err
+	}
 	err = dec.seek((4 - ((1 + l) % 4)) % 4) // padding
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Additional layer information
-	for dec.read < pos {
-		addInfo, err := dec.parseAdditionalLayerInfo()
-		if err != nil {
-			return nil, err
-		}
-		layer.setAdditionalInfo(addInfo)
-	}
-
-	return layer, nil
+	return dec.parseAdditionalLayerInfos(end, layer.setAdditionalInfo, nil)
 }
 
-func (dec *decoder) parseMask() (*Mask, error) {
+func (dec *decoder) parseMask(end int) (*Mask, error) {
 	buf, err := dec.readBytes(4)
 	if err != nil {
 		return nil, err
@@ -421,19 +744,25 @@ func (dec *decoder) parseMask() (*Mask, error) {
 	if size <= 0 {
 		return nil, nil
 	}
+	if err := dec.checkLength(size, end); err != nil {
+		return nil, err
+	}
 
 	buf, err = dec.readBytes(size, true)
 	if err != nil {
 		return nil, err
 	}
+	if size < 18 {
+		return nil, errors.New("psd: invalid mask size")
+	}
 
 	mask := newMask()
 
 	mask.setRect(
-		int(util.ReadUint32(buf, 0)),
-		int(util.ReadUint32(buf, 4)),
-		int(util.ReadUint32(buf, 8)),
-		int(util.ReadUint32(buf, 12)),
+		int(util.ReadInt32(buf, 0)),
+		int(util.ReadInt32(buf, 4)),
+		int(util.ReadInt32(buf, 8)),
+		int(util.ReadInt32(buf, 12)),
 	)
 
 	// default color. 0 or 255
@@ -443,39 +772,122 @@ func (dec *decoder) parseMask() (*Mask, error) {
 	mask.DefaultColor = buf[16]
 	mask.Flags = buf[17]
 
-	if size == 20 {
-		mask.Padding = buf[18:20]
+	// Flags:
+	// bit 0 = position relative to layer;
+	// bit 1 = layer mask disabled;
+	// bit 2 = invert layer mask when blending (obsolete);
+	// bit 3 = the user mask actually came from rendering other data;
+	// bit 4 = the user and/or vector masks have parameters applied to them
+	mask.PositionRelative = (mask.Flags & (1 << 0)) != 0
+	mask.Disabled = (mask.Flags & (1 << 1)) != 0
+	mask.Invert = (mask.Flags & (1 << 2)) != 0
+	mask.FromRendering = (mask.Flags & (1 << 3)) != 0
+	mask.HasParameters = (mask.Flags & (1 << 4)) != 0
+
+	read := 18
+	if mask.HasParameters {
+		var n int
+		mask.Parameters, n, err = parseMaskParameters(buf[read:])
+		if err != nil {
+			return nil, err
+		}
+		read += n
+	}
+
+	if size-read < 18 {
+		mask.Padding = buf[read:]
 	} else {
-		mask.RealFlags = &buf[18]
-		if buf[19] != 0x00 && buf[19] != 0xff {
+		mask.RealFlags = &buf[read]
+		if buf[read+1] != 0x00 && buf[read+1] != 0xff {
 			return nil, errors.New("psd: invalid real user mask background")
 		}
-		mask.RealBackground = &buf[19]
+		mask.RealBackground = &buf[read+1]
 		mask.setRectEnclosingMask(
-			int(util.ReadUint32(buf, 20)),
-			int(util.ReadUint32(buf, 24)),
-			int(util.ReadUint32(buf, 28)),
-			int(util.ReadUint32(buf, 32)),
+			int(util.ReadInt32(buf, read+2)),
+			int(util.ReadInt32(buf, read+6)),
+			int(util.ReadInt32(buf, read+10)),
+			int(util.ReadInt32(buf, read+14)),
 		)
 	}
 
 	return mask, nil
 }
 
-func (dec *decoder) parseGlobalLayerMask() (*GlobalLayerMask, error) {
+func parseMaskParameters(buf []byte) (*MaskParameters, int, error) {
+	invalid := errors.New("psd: invalid mask parameters")
+	if len(buf) < 1 {
+		return nil, 0, invalid
+	}
+
+	// bit 0 = user mask density, 1 byte;
+	// bit 1 = user mask feather, 8 byte double;
+	// bit 2 = vector mask density, 1 byte;
+	// bit 3 = vector mask feather, 8 bytes double
+	flags := buf[0]
+	read := 1
+	params := &MaskParameters{}
+	readDensity := func() (*int, error) {
+		if len(buf) < read+1 {
+			return nil, invalid
+		}
+		v := int(buf[read])
+		read++
+		return &v, nil
+	}
+	readFeather := func() (*float64, error) {
+		if len(buf) < read+8 {
+			return nil, invalid
+		}
+		v := util.ReadFloat64(buf, read)
+		read += 8
+		return &v, nil
+	}
+
+	var err error
+	if flags&(1<<0) != 0 {
+		if params.UserMaskDensity, err = readDensity(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if flags&(1<<1) != 0 {
+		if params.UserMaskFeather, err = readFeather(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if flags&(1<<2) != 0 {
+		if params.VectorMaskDensity, err = readDensity(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if flags&(1<<3) != 0 {
+		if params.VectorMaskFeather, err = readFeather(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return params, read, nil
+}
+
+func (dec *decoder) parseGlobalLayerMask(end int) (*GlobalLayerMask, error) {
 	buf, err := dec.readBytes(4)
 	if err != nil {
 		return nil, err
 	}
-	size := util.ReadUint32(buf, 0)
+	size := int(util.ReadUint32(buf, 0))
 	if size <= 0 {
 		return nil, nil
 	}
+	if err := dec.checkLength(size, end); err != nil {
+		return nil, err
+	}
 
-	buf, err = dec.readBytes(int(size))
+	buf, err = dec.readBytes(size)
 	if err != nil {
 		return nil, err
 	}
+	if size < 13 {
+		return nil, errors.New("psd: invalid global layer mask size")
+	}
 
 	colorComponents := make([]byte, 8)
 	for i, d := range buf[2:10] {
@@ -492,7 +904,7 @@ func (dec *decoder) parseGlobalLayerMask() (*GlobalLayerMask, error) {
 	return &mask, nil
 }
 
-func (dec *decoder) parseBlendingRanges() (*BlendingRanges, error) {
+func (dec *decoder) parseBlendingRanges(end int) (*BlendingRanges, error) {
 	buf, err := dec.readBytes(4)
 	if err != nil {
 		return nil, err
@@ -501,11 +913,17 @@ func (dec *decoder) parseBlendingRanges() (*BlendingRanges, error) {
 	if size <= 0 {
 		return nil, nil
 	}
+	if err := dec.checkLength(size, end); err != nil {
+		return nil, err
+	}
 
 	buf, err = dec.readBytes(size)
 	if err != nil {
 		return nil, err
 	}
+	if size < 8 {
+		return nil, errors.New("psd: invalid blending ranges size")
+	}
 
 	blendingRanges := newBlendingRanges()
 	blendingRanges.CompositeGrayBlend = &BlendingRangesData{
@@ -524,30 +942,104 @@ func (dec *decoder) parseBlendingRanges() (*BlendingRanges, error) {
 	return blendingRanges, nil
 }
 
-func (dec *decoder) parseAdditionalLayerInfo() (*AdditionalInfo, error) {
-	buf, err := dec.readBytes(4 * 3) // TODO: PSB
-	if err != nil {
-		return nil, err
+// parseAdditionalLayerInfos parses the additional layer information blocks
+// until end. In lenient mode a block failing to parse is kept raw, and a
+// broken block skips the rest up to end.
+//
+// stream, if not nil, is offered each block before its data is read. When it
+// reports the block as handled, it has parsed the data from the decoder up to
+// the given end of the block, and the block is set without its data.
+func (dec *decoder) parseAdditionalLayerInfos(end int, set func(*AdditionalInfo) error, stream func(key string, end int) (bool, error)) error {
+	for dec.read < end {
+		addInfo, err := dec.parseAdditionalLayerInfo(end, stream)
+		if err != nil {
+			if err := dec.tolerate(err); err != nil {
+				return err
+			}
+			dec.key = ""
+			return dec.seekTo(end)
+		}
+		if addInfo == nil {
+			break
+		}
+		if err := dec.tolerate(set(addInfo)); err != nil {
+			return err
+		}
+		dec.key = ""
 	}
+	return nil
+}
 
-	if !bytes.Equal(buf[0:4], layerSig) && !bytes.Equal(buf[0:4], additionalSig) {
-		return nil, errors.New("psd: invalid additional layer information")
+func (dec *decoder) parseAdditionalLayerInfo(end int, stream func(key string, end int) (bool, error)) (*AdditionalInfo, error) {
+	if dec.opts.Lenient && end-dec.read < sigLen+4+4 {
+		// trailing padding
+		return nil, dec.seekTo(end)
 	}
 
-	addInfo := &AdditionalInfo{}
-	addInfo.Key = util.ReadString(buf, 4, 8)
-
-	size := int(util.ReadUint32(buf, 8))
-
-	// FIXME: padding?
-	switch addInfo.Key {
-	case "Txt2":
-		if size%2 == 1 {
-			size += 1
+	buf, err := dec.readBytes(sigLen+4, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// skip the zero padding written by some exporters
+	for dec.opts.Lenient && buf[0] == 0 && dec.read < end {
+		b, err := dec.readBytes(1)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf[1:], b[0])
+	}
+
+	if !bytes.Equal(buf[0:4], layerSig) && !bytes.Equal(buf[0:4], additionalSig) {
+		if dec.opts.Lenient && bytes.Count(buf, []byte{0}) == len(buf) {
+			return nil, nil
+		}
+		return nil, errors.New("psd: invalid additional layer information")
+	}
+
+	addInfo := &AdditionalInfo{}
+	addInfo.Key = util.ReadString(buf, 4, 8)
+	dec.key = addInfo.Key
+
+	// PSB uses 8 bytes length for some keys
+	var size int
+	if dec.header.IsPSB() && additionalLongKeys[addInfo.Key] {
+		size, err = dec.readSize()
+	} else {
+		buf, err = dec.readBytes(4)
+		if err == nil {
+			size = int(util.ReadUint32(buf, 0))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dec.checkLength(size, end); err != nil {
+		return nil, err
+	}
+	if stream != nil {
+		blockEnd := dec.read + size
+		ok, err := stream(addInfo.Key, blockEnd)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return addInfo, dec.seekTo(blockEnd)
+		}
+	}
+	if !dec.opts.Lenient {
+		// in lenient mode the padding is skipped before the next signature instead
+		// FIXME: padding?
+		switch addInfo.Key {
+		case "Txt2":
+			if size%2 == 1 {
+				size += 1
+			}
+			size += 2
+		case "LMsk":
+			size += 2
 		}
-		size += 2
-	case "LMsk":
-		size += 2
 	}
 
 	buf, err = dec.readBytes(size, true)
@@ -559,16 +1051,116 @@ func (dec *decoder) parseAdditionalLayerInfo() (*AdditionalInfo, error) {
 	return addInfo, nil
 }
 
-func (dec *decoder) parseChannelImageData(layer *Layer) (Image, error) {
-	var method int
-	img := map[int][]byte{}
+// channelData is the compressed image data of a channel. Reading the
+// payloads is separated from decompressing them, which can run concurrently.
+type channelData struct {
+	layer  int
+	offset int
+	id     int
+	method int
+	rect   image.Rectangle
+	depth  int
+	psb    bool
+	buf    []byte
+	lens   []int
+	dest   []byte
+	// err is kept instead of failing the decode in lenient mode
+	err error
+}
+
+func (c *channelData) decode() error {
+	size := imageSize(c.rect, c.depth)
+	switch c.method {
+	case imgRAW:
+		if len(c.buf) < size {
+			return errors.New("psd: invalid raw image data length")
+		}
+		c.dest = c.buf[:size]
+	case imgRLE:
+		if c.lens == nil {
+			// the row byte counts precede the rows in the layer channels
+			var n int
+			c.lens, n = rleLengths(c.buf, c.rect.Dy(), c.psb)
+			if c.lens == nil {
+				return errors.New("psd: invalid RLE image data length")
+			}
+			c.buf = c.buf[n:]
+		}
+		total := 0
+		for _, l := range c.lens {
+			total += l
+		}
+		// a PackBits run of 2 bytes expands to 128 bytes at most
+		if total > len(c.buf) || size > total*64 {
+			return errors.New("psd: invalid RLE image data length")
+		}
+		c.dest = make([]byte, size)
+		if err := decodePackBitsPerLine(c.dest, c.buf, c.lens); err != nil {
+			return err
+		}
+	case imgZIPWithOutPrediction, imgZIPWithPrediction:
+		dest, err := decodeZIP(size, bytes.NewReader(c.buf))
+		if err != nil {
+			return err
+		}
+		c.dest = dest
+		if c.method == imgZIPWithPrediction {
+			if err := decodePrediction(c.dest, c.rect.Dx(), c.rect.Dy(), c.depth); err != nil {
+				return err
+			}
+		}
+	}
+	c.buf = nil
+	return nil
+}
+
+// decodeChannels decompresses the channels with up to Options.Workers goroutines.
+func (dec *decoder) decodeChannels(channels []*channelData) error {
+	err := parallel(dec.opts.Workers, len(channels), func(i int) error {
+		if err := dec.ctx.Err(); err != nil {
+			return err
+		}
+		c := channels[i]
+		if err := c.decode(); err != nil {
+			c.err = &FormatError{Section: dec.section, Layer: c.layer, Offset: int64(c.offset), Err: err}
+			if !dec.opts.Lenient {
+				return c.err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range channels {
+		if c.err != nil {
+			dec.warnings = append(dec.warnings, c.err)
+		}
+	}
+	return nil
+}
+
+func (dec *decoder) parseChannelImageData(layer *Layer) error {
+	channels, err := dec.readChannelImageData(layer)
+	if err != nil {
+		return err
+	}
+	if err := dec.decodeChannels(channels); err != nil {
+		return err
+	}
+	return dec.setLayerImages(layer, channels)
+}
+
+// readChannelImageData reads the compressed channel image data of the layer.
+func (dec *decoder) readChannelImageData(layer *Layer) ([]*channelData, error) {
+	var channels []*channelData
 	for _, channel := range layer.Channels {
 		buf, err := dec.readBytes(compressionLen)
 		if err != nil {
 			return nil, err
 		}
 
-		method = int(util.ReadUint16(buf, 0))
+		method := int(util.ReadUint16(buf, 0))
 
 		if channel.Length == 2 {
 			continue
@@ -577,216 +1169,635 @@ func (dec *decoder) parseChannelImageData(layer *Layer) (Image, error) {
 		var rect image.Rectangle
 		switch channel.ID {
 		case -3:
+			if layer.Mask == nil || layer.Mask.RectEnclosingMask == nil {
+				if err := dec.seek(channel.Length - compressionLen); err != nil {
+					return nil, err
+				}
+				continue
+			}
 			rect = *layer.Mask.RectEnclosingMask
 		case -2:
+			if layer.Mask == nil {
+				if err := dec.seek(channel.Length - compressionLen); err != nil {
+					return nil, err
+				}
+				continue
+			}
 			rect = layer.Mask.Rect
 		default:
 			rect = layer.Rect
 		}
 
-		var imgCh []byte
+		if err := dec.checkRect(rect); err != nil {
+			return nil, err
+		}
+		if err := dec.addPixelBytes(imageSize(rect, dec.header.Depth)); err != nil {
+			return nil, err
+		}
+
+		c := &channelData{
+			layer:  dec.layer,
+			offset: dec.read,
+			id:     channel.ID,
+			method: method,
+			rect:   rect,
+			depth:  dec.header.Depth,
+			psb:    dec.header.IsPSB(),
+		}
 		switch method {
-		case imgRAW:
-			// Raw Image
-			imgCh, err = dec.parseChannelImageRaw(rect)
-		case imgRLE:
-			// RLE
-			imgCh, err = dec.parseChannelImageRLE(rect)
+		case imgRAW, imgRLE, imgZIPWithOutPrediction, imgZIPWithPrediction:
+			c.buf, err = dec.readBytes(channel.Length-compressionLen, true)
+			if err != nil {
+				return nil, err
+			}
 		default:
-			return nil, fmt.Errorf("psd: unknown compression method=%d", method)
+			err := fmt.Errorf("psd: unknown compression method=%d", method)
+			if err := dec.tolerate(err); err != nil {
+				return nil, err
+			}
+			if err := dec.seek(channel.Length - compressionLen); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
-		if err != nil {
-			return nil, err
+		channels = append(channels, c)
+	}
+	return channels, nil
+}
+
+// setLayerImages builds the layer and mask images from the decompressed channels.
+func (dec *decoder) setLayerImages(layer *Layer, channels []*channelData) error {
+	var (
+		method int
+		broken bool
+	)
+	img := map[int][]byte{}
+	for _, c := range channels {
+		if c.err != nil {
+			// a broken color channel leaves the layer without an image
+			broken = broken || c.id >= -1
+			continue
 		}
-		if channel.ID >= -1 {
-			img[channel.ID] = imgCh
+		method = c.method
+		switch c.id {
+		case -3:
+			layer.RealMaskImage = psdImage.NewMask(c.rect, dec.header.Depth, *layer.Mask.RealBackground, c.dest)
+		case -2:
+			layer.MaskImage = psdImage.NewMask(c.rect, dec.header.Depth, layer.Mask.DefaultColor, c.dest)
+		default:
+			img[c.id] = c.dest
 		}
 	}
 
-	if len(img) <= 0 {
-		return nil, nil
+	if len(img) <= 0 || broken {
+		return nil
 	}
 
-	hasAlpha := dec.header.ColorMode.Channels() < len(img)
-	p, err := newImage(dec.header.ColorMode, dec.header.Depth, method, hasAlpha)
+	_, hasAlpha := img[-1]
+	p, err := dec.newImage(method, hasAlpha)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if p == nil {
+		return nil
 	}
-	p.Source(layer.Rect, img[0], img[1], img[2], img[-1])
 
-	return p, nil
+	// color channels followed by the transparency mask
+	n := dec.header.ColorMode.Channels()
+	src := make([][]byte, 0, n+1)
+	for i := 0; i < n; i++ {
+		if img[i] == nil {
+			// a layer missing one of its color channels has no image
+			return nil
+		}
+		src = append(src, img[i])
+	}
+	if hasAlpha {
+		src = append(src, img[-1])
+	}
+	p.Source(layer.Rect, src...)
+	layer.Image = p
+
+	return nil
 }
 
-func (dec *decoder) parseChannelImageRaw(rect image.Rectangle) ([]byte, error) {
-	size := rect.Dx() * rect.Dy()
-	img, err := dec.readBytes(size, true)
+func imageSize(rect image.Rectangle, depth int) int {
+	return (rect.Dx()*depth + 7) >> 3 * rect.Dy()
+}
+
+// parseRLELengths reads the byte counts of the RLE compressed rows.
+// Each count is 2 bytes in PSD and 4 bytes in PSB.
+func (dec *decoder) parseRLELengths(rows int) ([]int, int, error) {
+	n := util.GetSize(dec.header.IsPSB()) / 2
+	buf, err := dec.readBytes(rows * n)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return img, nil
+	lens, _ := rleLengths(buf, rows, dec.header.IsPSB())
+	var total int
+	for _, l := range lens {
+		total += l
+	}
+	return lens, total, nil
 }
 
-func (dec *decoder) parseChannelImageRLE(rect image.Rectangle) ([]byte, error) {
-	size := rect.Dy() * 4 / 2 // TODO: PSB 4 -> 8
-	buf, err := dec.readBytes(size)
-	if err != nil {
-		return nil, err
+// rleLengths reads the byte counts of rows from buf and returns them with
+// the number of bytes read, or nil if buf is too short.
+func rleLengths(buf []byte, rows int, psb bool) ([]int, int) {
+	n := util.GetSize(psb) / 2
+	if len(buf) < rows*n {
+		return nil, 0
 	}
-	lens := make([]int, rect.Dy())
-	var total, n int
+	lens := make([]int, rows)
 	for i := range lens {
-		l := int(util.ReadUint16(buf, n))
-		n += 2
-		total += l
-		lens[i] = l
+		if n == 4 {
+			lens[i] = int(util.ReadUint32(buf, i*n))
+		} else {
+			lens[i] = int(util.ReadUint16(buf, i*n))
+		}
+	}
+	return lens, rows * n
+}
+
+// decodePackBitsPerLine decodes the PackBits compressed rows of buf into dest.
+func decodePackBitsPerLine(dest []byte, buf []byte, lens []int) error {
+	invalid := errors.New("psd: invalid RLE image data")
+	for _, ln := range lens {
+		if ln > len(buf) {
+			return invalid
+		}
+		line := buf[:ln]
+		buf = buf[ln:]
+		for i := 0; i < len(line); {
+			n := int(int8(line[i]))
+			i++
+			switch {
+			case n >= 0:
+				// n+1 literal bytes
+				l := n + 1
+				if i+l > len(line) || l > len(dest) {
+					return invalid
+				}
+				copy(dest, line[i:i+l])
+				dest = dest[l:]
+				i += l
+			case n > -128:
+				// one byte repeated 1-n times
+				l := 1 - n
+				if i >= len(line) || l > len(dest) {
+					return invalid
+				}
+				for j, c := 0, line[i]; j < l; j++ {
+					dest[j] = c
+				}
+				dest = dest[l:]
+				i++
+			}
+			// -128 is a no-op
+		}
 	}
-	buf, err = dec.readBytes(total)
+	return nil
+}
+
+// decodeZIP inflates size bytes. The destination grows with the inflated data
+// instead of trusting the declared size, since a few compressed bytes can claim
+// an arbitrarily large image.
+func decodeZIP(size int, r io.Reader) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
 	if err != nil {
 		return nil, err
 	}
+	defer zr.Close()
 
-	size = (rect.Dx()*dec.header.Depth + 7) >> 3 * rect.Dy()
-	dest := make([]byte, size)
-	decodePackBitsPerLine(dest, buf, lens)
-
-	return dest, nil
+	data, _, err := readGrowing(zr, size)
+	return data, err
 }
 
-func decodePackBitsPerLine(dest []byte, buf []byte, lens []int) {
-	var l int
-	for _, ln := range lens {
-		for i := 0; i < ln; {
-			if buf[i] <= 0x7f {
-				l = int(buf[i]) + 1
-				copy(dest[:l], buf[i+1:])
-				dest = dest[l:]
-				i += l + 1
-				continue
+// decodePrediction reverses the per row delta encoding of ZIP with prediction.
+func decodePrediction(data []byte, width, height, depth int) error {
+	if len(data) < width*height*depth/8 {
+		return errors.New("psd: invalid prediction data length")
+	}
+	switch depth {
+	case 8:
+		for y := 0; y < height; y++ {
+			row := data[y*width : (y+1)*width]
+			for x := 1; x < width; x++ {
+				row[x] += row[x-1]
 			}
-			l = int(-buf[i]) + 1
-			for j, c := 0, buf[i+1]; j < l; j++ {
-				dest[j] = c
+		}
+	case 16:
+		for y := 0; y < height; y++ {
+			row := data[y*width*2 : (y+1)*width*2]
+			for x := 2; x < len(row); x += 2 {
+				v := binary.BigEndian.Uint16(row[x:]) + binary.BigEndian.Uint16(row[x-2:])
+				binary.BigEndian.PutUint16(row[x:], v)
 			}
-			dest = dest[l:]
-			i += 2
 		}
-		buf = buf[ln:]
+	case 32:
+		// The deltas are computed per byte over the whole row, and the bytes of
+		// each value are stored as 4 planes (most significant byte first).
+		tmp := make([]byte, width*4)
+		for y := 0; y < height; y++ {
+			row := data[y*width*4 : (y+1)*width*4]
+			for x := 1; x < len(row); x++ {
+				row[x] += row[x-1]
+			}
+			copy(tmp, row)
+			for x := 0; x < width; x++ {
+				row[x*4] = tmp[x]
+				row[x*4+1] = tmp[x+width]
+				row[x*4+2] = tmp[x+width*2]
+				row[x*4+3] = tmp[x+width*3]
+			}
+		}
+	default:
+		return fmt.Errorf("psd: unsupported prediction depth=%d", depth)
 	}
+	return nil
 }
 
-func (dec *decoder) parseImageData() (image.Image, error) {
+func (dec *decoder) parseImageData() ([][]byte, int, error) {
 	buf, err := dec.readBytes(compressionLen)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	method := int(util.ReadUint16(buf, 0))
 
+	if err := dec.addPixelBytes(imageSize(dec.header.Rect(), dec.header.Depth) * dec.header.Channels); err != nil {
+		return nil, 0, err
+	}
+
+	var img [][]byte
 	switch method {
 	case imgRAW:
-		return dec.parseImageRAW()
+		img, err = dec.parseImageRAW()
 	case imgRLE:
-		return dec.parseImageRLE()
+		img, err = dec.parseImageRLE()
+	case imgZIPWithOutPrediction, imgZIPWithPrediction:
+		img, err = dec.parseImageZIP(method)
 	default:
-		return nil, fmt.Errorf("psd: unknown compression method=%d", method)
+		return nil, 0, fmt.Errorf("psd: unknown compression method=%d", method)
 	}
-	return nil, nil
+	return img, method, err
 }
 
-func (dec *decoder) parseImageRAW() (Image, error) {
-	size := dec.header.Width * dec.header.Height * (dec.header.Depth / 8)
+func (dec *decoder) parseImageRAW() ([][]byte, error) {
+	size := imageSize(dec.header.Rect(), dec.header.Depth)
 	img := make([][]byte, dec.header.Channels)
 	var err error
 
 	for i := 0; i < dec.header.Channels; i++ {
+		if err := dec.checkpoint(); err != nil {
+			return nil, err
+		}
 		img[i], err = dec.readBytes(size, true)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	p, err := newImage(dec.header.ColorMode, dec.header.Depth, imgRAW, false)
+	return img, nil
+}
+
+func (dec *decoder) parseImageRLE() ([][]byte, error) {
+	rect := dec.header.Rect()
+	lens, _, err := dec.parseRLELengths(rect.Dy() * dec.header.Channels)
 	if err != nil {
 		return nil, err
 	}
-	p.Source(dec.header.Rect(), img...)
 
-	return p, nil
-}
+	channels := make([]*channelData, dec.header.Channels)
+	for i := range channels {
+		if err := dec.checkpoint(); err != nil {
+			return nil, err
+		}
+		c := &channelData{layer: -1, offset: dec.read, method: imgRLE, rect: rect, depth: dec.header.Depth}
+		c.lens = lens[i*rect.Dy() : (i+1)*rect.Dy()]
+		total := 0
+		for _, n := range c.lens {
+			total += n
+		}
+		c.buf, err = dec.readBytes(total, true)
+		if err != nil {
+			return nil, err
+		}
+		channels[i] = c
+	}
+	if err := dec.decodeChannels(channels); err != nil {
+		return nil, err
+	}
 
-func (dec *decoder) parseImageRLE() (Image, error) {
+	img := make([][]byte, len(channels))
+	for i, c := range channels {
+		if c.err != nil {
+			// already a warning in lenient mode, the composite is left out
+			return nil, nil
+		}
+		img[i] = c.dest
+	}
+	return img, nil
+}
 
-	lineLen := make([]int, dec.header.Height*dec.header.Channels)
-	for i := range lineLen {
-		buf, err := dec.readBytes(2)
+// parseImageZIP decodes the composite image, which is compressed
+// as a single stream containing all channels.
+func (dec *decoder) parseImageZIP(method int) ([][]byte, error) {
+	rect := dec.header.Rect()
+	size := imageSize(rect, dec.header.Depth)
+	data, err := decodeZIP(size*dec.header.Channels, dec.r)
+	if err != nil {
+		return nil, err
+	}
+	if method == imgZIPWithPrediction {
+		err := decodePrediction(data, rect.Dx(), rect.Dy()*dec.header.Channels, dec.header.Depth)
 		if err != nil {
 			return nil, err
 		}
-		lineLen[i] = int(util.ReadUint16(buf, 0))
 	}
 
 	img := make([][]byte, dec.header.Channels)
-	d := dec.header.Depth / 8
-	for i := 0; i < dec.header.Channels; i++ {
-		lines := make([]byte, 0, dec.header.Height)
-		size := 0
-		for j := 0; j < dec.header.Height; j++ {
-			n := lineLen[i*dec.header.Height+j] * d
-			line, err := dec.readPackBits(n)
-			if err != nil {
-				return nil, err
-			}
-			lines = append(lines, line...)
-			size += len(line)
+	for i := range img {
+		img[i] = data[i*size : (i+1)*size]
+	}
+
+	return img, nil
+}
+
+func (dec *decoder) newImage(method int, hasAlpha bool) (Image, error) {
+	p, err := newImage(dec.header.ColorMode, dec.header.Depth, method, hasAlpha)
+	if err != nil {
+		return nil, err
+	}
+	if indexed, ok := p.(*psdImage.Indexed); ok {
+		indexed.Palette = dec.palette
+	}
+	return p, nil
+}
+
+// compositeChannels returns the number of channels of the composite image,
+// including the merged transparency, and whether the transparency is present.
+func (dec *decoder) compositeChannels() (int, bool) {
+	n := dec.header.ColorMode.Channels()
+	if n < 0 || n > dec.header.Channels {
+		n = dec.header.Channels
+	}
+	hasAlpha := dec.mergedAlpha && dec.header.Channels > n
+	if hasAlpha {
+		n++
+	}
+	return n, hasAlpha
+}
+
+// newCompositeImage builds the composite image from the color channels and
+// the merged transparency. The remaining channels are returned as alpha channels.
+func (dec *decoder) newCompositeImage(method int, img [][]byte, names []string) (Image, []*AlphaChannel, error) {
+	n, hasAlpha := dec.compositeChannels()
+	if n > len(img) {
+		n = len(img)
+	}
+
+	p, err := dec.newImage(method, hasAlpha)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p != nil {
+		p.Source(dec.header.Rect(), img[:n]...)
+	}
+
+	var alphas []*AlphaChannel
+	for i, data := range img[n:] {
+		gray, err := newImageGrayScale(dec.header.Depth, method, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		gray.Source(dec.header.Rect(), data)
+		alpha := &AlphaChannel{Image: gray}
+		if i < len(names) {
+			alpha.Name = names[i]
 		}
-		img[i] = lines
+		alphas = append(alphas, alpha)
 	}
 
-	p, err := newImage(dec.header.ColorMode, dec.header.Depth, imgRLE, false)
+	return p, alphas, nil
+}
+
+func init() {
+	image.RegisterFormat("psd", string(headerSig), decodeImage, DecodeConfig)
+}
+
+// decodeImage returns the composite image for image.Decode. The layer images
+// are skipped, and so are the image resources unless the palette of an
+// indexed image needs its transparency index.
+func decodeImage(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	indexed := false
+	if buf, err := br.Peek(headerLen); err == nil {
+		indexed = ColorMode(util.ReadUint16(buf, headerLen-headerLens[7])) == ColorModeIndexed
+	}
+	psd, err := DecodeWithOptions(br, &Options{
+		SkipLayerImages:    true,
+		SkipImageResources: !indexed,
+	})
 	if err != nil {
 		return nil, err
 	}
-	p.Source(dec.header.Rect(), img...)
+	if psd.Image == nil {
+		return nil, errors.New("psd: no composite image")
+	}
+	return psd.Image, nil
+}
 
-	return p, nil
+// DecodeConfig returns the color model and dimensions of the composite image
+// without decoding the entire file. The header is read, the palette of indexed
+// images and the layer count, which tells if the composite has merged transparency.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	dec := newDecoder(r, nil)
+
+	dec.section = sectionHeader
+	if err := dec.parseHeader(); err != nil {
+		return image.Config{}, dec.formatError(err)
+	}
+
+	indexed := dec.header.ColorMode == ColorModeIndexed
+	dec.section = sectionColorMode
+	colorModeData, err := dec.parseColorModeData()
+	if err != nil {
+		return image.Config{}, dec.formatError(err)
+	}
+
+	// the transparency index of indexed images is an image resource
+	dec.section = sectionResources
+	dec.opts.SkipImageResources = !indexed
+	blocks, err := dec.parseImageResources()
+	if err != nil {
+		return image.Config{}, dec.formatError(err)
+	}
+	if indexed {
+		dec.palette = colorModeData.Palette(transparencyIndex(blocks))
+	}
+
+	if dec.header.Channels > dec.header.ColorMode.Channels() {
+		dec.section = sectionLayerInfo
+		if err := dec.parseLayerCount(); err != nil {
+			return image.Config{}, dec.formatError(err)
+		}
+	}
+	_, hasAlpha := dec.compositeChannels()
+
+	p, err := dec.newImage(imgRAW, hasAlpha)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if p == nil {
+		return image.Config{}, fmt.Errorf("psd: unsupported color mode=%d", dec.header.ColorMode)
+	}
+
+	return image.Config{
+		ColorModel: p.ColorModel(),
+		Width:      dec.header.Width,
+		Height:     dec.header.Height,
+	}, nil
+}
+
+// parseLayerCount reads the sign of the layer count, which tells if
+// the composite image has merged transparency, and nothing else.
+func (dec *decoder) parseLayerCount() error {
+	size, err := dec.readSize()
+	if err != nil || size <= 0 {
+		return err
+	}
+	end := dec.read + size
+
+	size, err = dec.readSize()
+	if err != nil {
+		return err
+	}
+	if err := dec.checkLength(size, end); err != nil {
+		return err
+	}
+	if size > 0 {
+		return dec.readLayerCountSign()
+	}
+
+	// 16 and 32 bit documents count their layers in an additional layer information block
+	buf, err := dec.readBytes(4)
+	if err != nil {
+		return err
+	}
+	size = int(util.ReadUint32(buf, 0))
+	if err := dec.checkLength(size, end); err != nil {
+		return err
+	}
+	if err := dec.seek(size); err != nil {
+		return err
+	}
+	found := false
+	stream := func(key string, end int) (bool, error) {
+		if !layerInfoKeys[key] || found {
+			return false, nil
+		}
+		found = true
+		return true, dec.readLayerCountSign()
+	}
+	return dec.parseAdditionalLayerInfos(end, func(*AdditionalInfo) error { return nil }, stream)
+}
+
+func (dec *decoder) readLayerCountSign() error {
+	buf, err := dec.readBytes(2)
+	if err != nil {
+		return err
+	}
+	dec.mergedAlpha = util.ReadInt16(buf, 0) < 0
+	return nil
 }
 
 func Decode(r io.Reader) (*PSD, error) {
-	dec := &decoder{r: r, header: &Header{}}
+	return DecodeWithOptions(r, nil)
+}
 
+// DecodeWithOptions decodes the PSD/PSB file with the given options.
+// If r implements io.Seeker the skipped sections are seeked over.
+func DecodeWithOptions(r io.Reader, opts *Options) (*PSD, error) {
+	return DecodeContext(context.Background(), r, opts)
+}
+
+// DecodeContext is like DecodeWithOptions but stops with the error of ctx
+// once it is canceled. The context is checked between the sections,
+// the layers and the channels.
+func DecodeContext(ctx context.Context, r io.Reader, opts *Options) (*PSD, error) {
+	dec := newDecoder(r, opts)
+	dec.ctx = ctx
+	return dec.decode()
+}
+
+func (dec *decoder) decode() (psd *PSD, err error) {
+	defer func() {
+		err = dec.formatError(err)
+	}()
+
+	if err := dec.enter(sectionHeader); err != nil {
+		return nil, err
+	}
 	if err := dec.parseHeader(); err != nil {
 		return nil, err
 	}
 
+	if err := dec.enter(sectionColorMode); err != nil {
+		return nil, err
+	}
 	colorModeData, err := dec.parseColorModeData()
 	if err != nil {
 		return nil, err
 	}
 
+	if err := dec.enter(sectionResources); err != nil {
+		return nil, err
+	}
 	blocks, err := dec.parseImageResources()
 	if err != nil {
 		return nil, err
 	}
+	dec.palette = colorModeData.Palette(transparencyIndex(blocks))
 
-	layers, globalMask, addInfos, err := dec.parseLayerAndMaskInfo()
-	if err != nil {
+	if err := dec.enter(sectionLayerInfo); err != nil {
 		return nil, err
 	}
-
-	img, err := dec.parseImageData()
+	layers, globalMask, addInfos, err := dec.parseLayerAndMaskInfo()
 	if err != nil {
 		return nil, err
 	}
 
-	psd := &PSD{
+	psd = &PSD{
 		Header:          dec.header,
 		ColorModeData:   colorModeData,
 		ImageResources:  blocks,
 		Layers:          layers,
+		Root:            newLayerTree(layers),
 		GlobalLayerMask: globalMask,
 		AdditionalInfos: addInfos,
-		Image:           img,
 	}
+
+	if dec.opts.SkipComposite {
+		psd.Warnings = dec.warnings
+		return psd, nil
+	}
+	if err := dec.enter(sectionImageData); err != nil {
+		return nil, err
+	}
+	if dec.ra != nil {
+		dec.setLazyComposite(psd, alphaNames(blocks))
+	} else {
+		data, method, err := dec.parseImageData()
+		if err == nil && data != nil {
+			psd.Image, psd.AlphaChannels, err = dec.newCompositeImage(method, data, alphaNames(blocks))
+		}
+		if err := dec.tolerate(err); err != nil {
+			return nil, err
+		}
+	}
+
+	psd.Warnings = dec.warnings
 	return psd, nil
 }
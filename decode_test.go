@@ -1,10 +1,23 @@
 package psd
 
 import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"github.com/yu-ichiko/go-psd/additional"
+	psdImage "github.com/yu-ichiko/go-psd/image"
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
 	"image"
+	"image/color"
 	"image/png"
+	"io"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
 	"testing"
 )
@@ -69,17 +82,1313 @@ func BenchmarkDecode(b *testing.B) {
 	}
 	defer file.Close()
 
-	b.ReportAllocs()
-	b.ResetTimer()
+	for _, workers := range []int{1, runtime.NumCPU()} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
 
-	for i := 0; i < b.N; i++ {
-		_, err = file.Seek(0, 0)
-		if err != nil {
-			b.Fatal(err)
+			for i := 0; i < b.N; i++ {
+				_, err = file.Seek(0, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				DecodeWithOptions(file, &Options{Workers: workers})
+			}
+		})
+	}
+}
+
+// BenchmarkDecode_Workers decodes a synthetic document of many layers,
+// as the test files are not always present.
+func BenchmarkDecode_Workers(b *testing.B) {
+	const size = 256
+	plane := func(seed int) []byte {
+		buf := make([]byte, size*size)
+		for i := range buf {
+			buf[i] = byte(seed + i/7 + (i/size)*3)
+		}
+		return buf
+	}
+
+	for _, method := range []int{imgRLE, imgZIPWithOutPrediction} {
+		doc := newTestRGBDocument(1)
+		doc.compression = method
+		doc.header.Width = size
+		doc.header.Height = size
+		doc.layers = nil
+		for i := 0; i < 32; i++ {
+			doc.layers = append(doc.layers, &testLayer{
+				rect:     image.Rect(0, 0, size, size),
+				channels: map[int][]byte{-1: plane(i), 0: plane(i + 1), 1: plane(i + 2), 2: plane(i + 3)},
+			})
+		}
+		doc.composite = [][]byte{plane(0), plane(1), plane(2)}
+		buf := doc.encode()
+
+		for _, workers := range []int{1, runtime.NumCPU()} {
+			b.Run(fmt.Sprintf("method=%d/workers=%d", method, workers), func(b *testing.B) {
+				b.ReportAllocs()
+				b.SetBytes(int64(len(buf)))
+				b.ResetTimer()
+
+				for i := 0; i < b.N; i++ {
+					if _, err := DecodeWithOptions(bytes.NewReader(buf), &Options{Workers: workers}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
 		}
-		Decode(file)
 	}
 }
 
 // 943,868,237
 // 943868237
+
+type testLayer struct {
+	rect     image.Rectangle
+	channels map[int][]byte
+	mask     []byte
+	maskRect image.Rectangle
+	extra    []byte
+}
+
+type testDocument struct {
+	header      Header
+	compression int
+	colorData   []byte
+	resources   []byte
+	layers      []*testLayer
+	mergedAlpha bool
+	// layerKey stores the layers in the additional layer information
+	// with the key instead of the layer info section
+	layerKey  string
+	composite [][]byte
+}
+
+// encodeTestRLE compresses every row with PackBits literal runs and
+// returns the byte counts table and the compressed rows.
+func encodeTestRLE(data []byte, rowLen int, psb bool) ([]byte, []byte) {
+	lens := &bytes.Buffer{}
+	rows := &bytes.Buffer{}
+	for len(data) > 0 {
+		row := data[:rowLen]
+		data = data[rowLen:]
+		n := rows.Len()
+		for len(row) > 0 {
+			l := len(row)
+			if l > 128 {
+				l = 128
+			}
+			rows.WriteByte(byte(l - 1))
+			rows.Write(row[:l])
+			row = row[l:]
+		}
+		if psb {
+			binary.Write(lens, binary.BigEndian, uint32(rows.Len()-n))
+		} else {
+			binary.Write(lens, binary.BigEndian, uint16(rows.Len()-n))
+		}
+	}
+	return lens.Bytes(), rows.Bytes()
+}
+
+// encodeTestZIP deflates data, computing the deltas first when prediction is used.
+func encodeTestZIP(data []byte, width, depth int, prediction bool) []byte {
+	data = append([]byte{}, data...)
+	if prediction {
+		rowLen := width * depth / 8
+		for y := 0; y < len(data)/rowLen; y++ {
+			row := data[y*rowLen : (y+1)*rowLen]
+			switch depth {
+			case 8:
+				for x := len(row) - 1; x > 0; x-- {
+					row[x] -= row[x-1]
+				}
+			case 16:
+				for x := len(row) - 2; x > 0; x -= 2 {
+					v := binary.BigEndian.Uint16(row[x:]) - binary.BigEndian.Uint16(row[x-2:])
+					binary.BigEndian.PutUint16(row[x:], v)
+				}
+			case 32:
+				tmp := append([]byte{}, row...)
+				for x := 0; x < width; x++ {
+					for i := 0; i < 4; i++ {
+						row[x+width*i] = tmp[x*4+i]
+					}
+				}
+				for x := len(row) - 1; x > 0; x-- {
+					row[x] -= row[x-1]
+				}
+			}
+		}
+	}
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+// encodeChannel compresses a layer channel and returns the data following the compression method.
+func (doc *testDocument) encodeChannel(data []byte, width int) []byte {
+	switch doc.compression {
+	case imgRAW:
+		return data
+	case imgZIPWithOutPrediction, imgZIPWithPrediction:
+		return encodeTestZIP(data, width, doc.header.Depth, doc.compression == imgZIPWithPrediction)
+	}
+	lens, rows := encodeTestRLE(data, doc.rowLen(width), doc.header.IsPSB())
+	return append(lens, rows...)
+}
+
+func writeTestSize(buf *bytes.Buffer, size int, psb bool) {
+	if psb {
+		binary.Write(buf, binary.BigEndian, uint64(size))
+		return
+	}
+	binary.Write(buf, binary.BigEndian, uint32(size))
+}
+
+func (doc *testDocument) rowLen(width int) int {
+	return (width*doc.header.Depth + 7) >> 3
+}
+
+// encode builds a PSD (or PSB) file whose channels use the document compression.
+func (doc *testDocument) encode() []byte {
+	psb := doc.header.IsPSB()
+	buf := &bytes.Buffer{}
+	buf.Write(headerSig)
+	binary.Write(buf, binary.BigEndian, uint16(doc.header.Version))
+	buf.Write(make([]byte, 6))
+	binary.Write(buf, binary.BigEndian, uint16(doc.header.Channels))
+	binary.Write(buf, binary.BigEndian, uint32(doc.header.Height))
+	binary.Write(buf, binary.BigEndian, uint32(doc.header.Width))
+	binary.Write(buf, binary.BigEndian, uint16(doc.header.Depth))
+	binary.Write(buf, binary.BigEndian, uint16(doc.header.ColorMode))
+
+	binary.Write(buf, binary.BigEndian, uint32(len(doc.colorData)))
+	buf.Write(doc.colorData)
+	binary.Write(buf, binary.BigEndian, uint32(len(doc.resources)))
+	buf.Write(doc.resources)
+
+	// Layer and mask information
+	layerInfo := &bytes.Buffer{}
+	if len(doc.layers) > 0 {
+		records := &bytes.Buffer{}
+		channels := &bytes.Buffer{}
+		count := int16(len(doc.layers))
+		if doc.mergedAlpha {
+			count = -count
+		}
+		binary.Write(records, binary.BigEndian, count)
+		for _, layer := range doc.layers {
+			binary.Write(records, binary.BigEndian, []int32{
+				int32(layer.rect.Min.Y), int32(layer.rect.Min.X),
+				int32(layer.rect.Max.Y), int32(layer.rect.Max.X),
+			})
+			ids := make([]int, 0, len(layer.channels))
+			for id := range layer.channels {
+				ids = append(ids, id)
+			}
+			sort.Ints(ids)
+			binary.Write(records, binary.BigEndian, uint16(len(ids)))
+			for _, id := range ids {
+				width := layer.rect.Dx()
+				if id == -2 {
+					width = layer.maskRect.Dx()
+				}
+				data := doc.encodeChannel(layer.channels[id], width)
+				binary.Write(records, binary.BigEndian, int16(id))
+				writeTestSize(records, 2+len(data), psb)
+				binary.Write(channels, binary.BigEndian, uint16(doc.compression))
+				channels.Write(data)
+			}
+			records.Write(layerSig)
+			records.WriteString("norm")
+			records.Write([]byte{255, 0, 0, 0})
+			extra := &bytes.Buffer{}
+			binary.Write(extra, binary.BigEndian, uint32(len(layer.mask)))
+			extra.Write(layer.mask)
+			extra.Write(make([]byte, 4)) // blending ranges
+			extra.Write([]byte{0, 0, 0, 0})
+			extra.Write(layer.extra)
+			binary.Write(records, binary.BigEndian, uint32(extra.Len()))
+			records.Write(extra.Bytes())
+		}
+		if (records.Len()+channels.Len())&1 != 0 {
+			channels.WriteByte(0)
+		}
+		if doc.layerKey != "" {
+			writeTestSize(layerInfo, 0, psb)
+			binary.Write(layerInfo, binary.BigEndian, uint32(0)) // global layer mask
+			layerInfo.Write(layerSig)
+			layerInfo.WriteString(doc.layerKey)
+		}
+		writeTestSize(layerInfo, records.Len()+channels.Len(), psb)
+		layerInfo.Write(records.Bytes())
+		layerInfo.Write(channels.Bytes())
+	} else {
+		writeTestSize(layerInfo, 0, psb)
+	}
+	writeTestSize(buf, layerInfo.Len(), psb)
+	buf.Write(layerInfo.Bytes())
+
+	// Image data
+	binary.Write(buf, binary.BigEndian, uint16(doc.compression))
+	switch doc.compression {
+	case imgRLE:
+		lens := &bytes.Buffer{}
+		rows := &bytes.Buffer{}
+		for _, ch := range doc.composite {
+			l, r := encodeTestRLE(ch, doc.rowLen(doc.header.Width), psb)
+			lens.Write(l)
+			rows.Write(r)
+		}
+		buf.Write(lens.Bytes())
+		buf.Write(rows.Bytes())
+	default:
+		buf.Write(doc.encodeChannel(bytes.Join(doc.composite, nil), doc.header.Width))
+	}
+
+	return buf.Bytes()
+}
+
+func newTestRGBDocument(version int) *testDocument {
+	plane := func(v byte, n int) []byte {
+		return bytes.Repeat([]byte{v}, n)
+	}
+	return &testDocument{
+		compression: imgRLE,
+		header: Header{
+			Version:   version,
+			Channels:  3,
+			Width:     4,
+			Height:    3,
+			Depth:     8,
+			ColorMode: ColorModeRGB,
+		},
+		layers: []*testLayer{
+			{
+				rect: image.Rect(1, 1, 3, 3),
+				channels: map[int][]byte{
+					-1: plane(0x80, 4),
+					0:  plane(0x10, 4),
+					1:  plane(0x20, 4),
+					2:  plane(0x30, 4),
+				},
+			},
+		},
+		composite: [][]byte{plane(0x40, 12), plane(0x50, 12), plane(0x60, 12)},
+	}
+}
+
+func TestDecode_PSB(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		doc := newTestRGBDocument(version)
+		psd, err := Decode(bytes.NewReader(doc.encode()))
+		require.NoError(t, err)
+
+		require.True(t, psd.Header.IsPSB() == (version == 2))
+		require.Len(t, psd.Layers, 1)
+		layer := psd.Layers[0]
+		require.Equal(t, image.Rect(1, 1, 3, 3), layer.Rect)
+		require.Equal(t, color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}, layer.Image.At(2, 2))
+		require.Equal(t, color.NRGBA{R: 0x40, G: 0x50, B: 0x60, A: 0xff}, psd.Image.At(3, 2))
+	}
+}
+
+func TestDecode_HeaderVersion(t *testing.T) {
+	doc := newTestRGBDocument(3)
+	_, err := Decode(bytes.NewReader(doc.encode()))
+	require.ErrorIs(t, err, ErrHeaderVersion)
+}
+
+func TestDecode_LayerBlock(t *testing.T) {
+	for _, version := range []int{1, 2} {
+		for key, depth := range map[string]int{"Layr": 8, "Lr16": 16, "Lr32": 32} {
+			n := depth / 8
+			plane := func(v byte) []byte {
+				return bytes.Repeat([]byte{v}, 4*n)
+			}
+			doc := newTestRGBDocument(version)
+			doc.layerKey = key
+			doc.header.Depth = depth
+			doc.layers[0].channels = map[int][]byte{0: plane(1), 1: plane(2), 2: plane(3), -1: plane(4)}
+			doc.layers = append(doc.layers, &testLayer{
+				rect:  image.Rect(0, 0, 1, 1),
+				extra: testSectionDivider(3, "pass"),
+			})
+			doc.composite = [][]byte{bytes.Repeat([]byte{5}, 12*n), bytes.Repeat([]byte{6}, 12*n), bytes.Repeat([]byte{7}, 12*n)}
+			buf := doc.encode()
+
+			psd, err := Decode(bytes.NewReader(buf))
+			require.NoError(t, err, "version=%d key=%s", version, key)
+			require.Len(t, psd.Layers, 2)
+			require.Equal(t, image.Rect(1, 1, 3, 3), psd.Layers[0].Rect)
+			layer := doc.layers[0].channels
+			require.Equal(t, [][]byte{layer[0], layer[1], layer[2], layer[-1]}, testPlanes(psd.Layers[0].Image))
+			require.True(t, psd.Layers[1].IsSectionBounding())
+			require.Len(t, psd.Root.Children, 1)
+			require.Len(t, psd.AdditionalInfos, 1)
+			require.Equal(t, key, psd.AdditionalInfos[0].Key)
+			require.Nil(t, psd.AdditionalInfos[0].Data)
+			require.Equal(t, doc.composite, testPlanes(psd.Image))
+
+			// the block is streamed, so a limit below its size still decodes
+			i := bytes.Index(buf, []byte("8BIM"+key)) + 8
+			size := int(binary.BigEndian.Uint32(buf[i:]))
+			if version == 2 {
+				size = int(binary.BigEndian.Uint64(buf[i:]))
+			}
+			limited, err := DecodeWithOptions(bytes.NewReader(buf), &Options{Limits: Limits{MaxBlockSize: size - 1}})
+			require.NoError(t, err)
+			require.Len(t, limited.Layers, 2)
+
+			skipped, err := DecodeWithOptions(bytes.NewReader(buf), &Options{SkipLayerImages: true})
+			require.NoError(t, err)
+			require.Len(t, skipped.Layers, 2)
+			require.Nil(t, skipped.Layers[0].Image)
+			require.Nil(t, skipped.AdditionalInfos[0].Data)
+
+			lazy, err := OpenReaderAt(bytes.NewReader(buf), int64(len(buf)))
+			require.NoError(t, err)
+			require.Len(t, lazy.Layers, 2)
+			require.IsType(t, &LazyImage{}, lazy.Layers[0].Image)
+			require.Equal(t, psd.Layers[0].Image.At(2, 2), lazy.Layers[0].Image.At(2, 2))
+		}
+	}
+
+	// broken layer record in the block
+	doc := newTestRGBDocument(1)
+	doc.layerKey = "Lr16"
+	doc.header.Depth = 16
+	doc.layers[0].channels = map[int][]byte{0: make([]byte, 8), 1: make([]byte, 8), 2: make([]byte, 8)}
+	doc.composite = [][]byte{make([]byte, 24), make([]byte, 24), make([]byte, 24)}
+	buf := doc.encode()
+	i := bytes.Index(buf, []byte("8BIMnorm"))
+	copy(buf[i:], "XXXX")
+
+	_, err := Decode(bytes.NewReader(buf))
+	require.ErrorIs(t, err, ErrLayerSignature)
+	var fe *FormatError
+	require.ErrorAs(t, err, &fe)
+	require.Equal(t, "Lr16", fe.Key)
+	require.Equal(t, 0, fe.Layer)
+	require.Equal(t, int64(i+16), fe.Offset)
+
+	psd, err := DecodeWithOptions(bytes.NewReader(buf), &Options{Lenient: true})
+	require.NoError(t, err)
+	require.Empty(t, psd.Layers)
+	require.Len(t, psd.Warnings, 1)
+	require.NotNil(t, psd.Image)
+}
+
+func TestDecode_ZIP(t *testing.T) {
+	for _, depth := range []int{8, 16, 32} {
+		for _, method := range []int{imgRAW, imgZIPWithOutPrediction, imgZIPWithPrediction} {
+			n := depth / 8
+			plane := func(v byte) []byte {
+				buf := make([]byte, 12*n)
+				for i := range buf {
+					buf[i] = v + byte(i*7)
+				}
+				return buf
+			}
+			doc := newTestRGBDocument(1)
+			doc.compression = method
+			doc.header.Depth = depth
+			doc.layers[0].rect = image.Rect(0, 0, 4, 3)
+			doc.layers[0].channels = map[int][]byte{0: plane(1), 1: plane(2), 2: plane(3), -1: plane(4)}
+			doc.composite = [][]byte{plane(5), plane(6), plane(7)}
+
+			psd, err := Decode(bytes.NewReader(doc.encode()))
+			require.NoError(t, err, "depth=%d method=%d", depth, method)
+
+			layer := doc.layers[0].channels
+			require.Equal(t, [][]byte{layer[0], layer[1], layer[2], layer[-1]}, testPlanes(psd.Layers[0].Image))
+			require.Equal(t, doc.composite, testPlanes(psd.Image))
+		}
+	}
+}
+
+func testPlanes(img image.Image) [][]byte {
+	switch p := img.(type) {
+	case *psdImage.NRGB8:
+		return [][]byte{p.R, p.G, p.B}
+	case *psdImage.NRGBA8:
+		return [][]byte{p.R, p.G, p.B, p.A}
+	case *psdImage.NRGB16:
+		return [][]byte{p.R, p.G, p.B}
+	case *psdImage.NRGBA16:
+		return [][]byte{p.R, p.G, p.B, p.A}
+	case *psdImage.NRGB32:
+		return [][]byte{p.R, p.G, p.B}
+	case *psdImage.NRGBA32:
+		return [][]byte{p.R, p.G, p.B, p.A}
+	case *psdImage.Gray8:
+		return [][]byte{p.Y}
+	case *psdImage.GrayA8:
+		return [][]byte{p.Y, p.A}
+	case *psdImage.Gray16:
+		return [][]byte{p.Y}
+	case *psdImage.GrayA16:
+		return [][]byte{p.Y, p.A}
+	case *psdImage.Gray32:
+		return [][]byte{p.Y}
+	case *psdImage.GrayA32:
+		return [][]byte{p.Y, p.A}
+	}
+	return nil
+}
+
+func TestDecode_GrayScale(t *testing.T) {
+	for _, depth := range []int{8, 16, 32} {
+		n := depth / 8
+		doc := &testDocument{
+			compression: imgRLE,
+			header: Header{
+				Version:   1,
+				Channels:  1,
+				Width:     2,
+				Height:    2,
+				Depth:     depth,
+				ColorMode: ColorModeGrayScale,
+			},
+			layers: []*testLayer{
+				{
+					rect: image.Rect(0, 0, 2, 1),
+					channels: map[int][]byte{
+						-1: bytes.Repeat([]byte{0xff}, 2*n),
+						0:  bytes.Repeat([]byte{0x40}, 2*n),
+					},
+				},
+			},
+			composite: [][]byte{bytes.Repeat([]byte{0x3f}, 4*n)},
+		}
+
+		psd, err := Decode(bytes.NewReader(doc.encode()))
+		require.NoError(t, err)
+
+		layer := doc.layers[0].channels
+		require.Equal(t, [][]byte{layer[0], layer[-1]}, testPlanes(psd.Layers[0].Image))
+		require.Equal(t, doc.composite, testPlanes(psd.Image))
+	}
+
+	img := &psdImage.Gray16{}
+	img.Source(image.Rect(0, 0, 1, 1), []byte{0x12, 0x34})
+	require.Equal(t, color.Gray16{Y: 0x1234}, img.At(0, 0))
+}
+
+func TestDecode_CMYK(t *testing.T) {
+	plane := func(v byte) []byte {
+		return []byte{v, v}
+	}
+	doc := &testDocument{
+		compression: imgRLE,
+		header: Header{
+			Version:   1,
+			Channels:  4,
+			Width:     2,
+			Height:    1,
+			Depth:     8,
+			ColorMode: ColorModeCMYK,
+		},
+		layers: []*testLayer{
+			{
+				rect: image.Rect(0, 0, 2, 1),
+				channels: map[int][]byte{
+					-1: plane(0x80),
+					0:  plane(0x00),
+					1:  plane(0xff),
+					2:  plane(0xff),
+					3:  plane(0xff),
+				},
+			},
+		},
+		composite: [][]byte{plane(0xff), plane(0x00), plane(0xff), plane(0xff)},
+	}
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	// cyan with half transparency
+	layer := psd.Layers[0].Image
+	require.Equal(t, pixelColor.NCMYKA{C: 0xff, A: 0x80}, layer.At(1, 0))
+	require.Equal(t, color.NRGBA{R: 0, G: 0xff, B: 0xff, A: 0x80}, color.NRGBAModel.Convert(layer.At(1, 0)))
+
+	// magenta
+	require.Equal(t, color.CMYKModel, psd.Image.ColorModel())
+	require.Equal(t, color.CMYK{M: 0xff}, psd.Image.At(0, 0))
+	r, g, b, a := psd.Image.At(0, 0).RGBA()
+	require.Equal(t, []uint32{0xffff, 0, 0xffff, 0xffff}, []uint32{r, g, b, a})
+}
+
+func testImageResource(id int, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(imgResSig)
+	binary.Write(buf, binary.BigEndian, uint16(id))
+	buf.Write([]byte{0, 0}) // empty name
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)&1 != 0 {
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode_Indexed(t *testing.T) {
+	colorData := make([]byte, 768)
+	for i := 0; i < 256; i++ {
+		colorData[i] = byte(i)
+		colorData[i+256] = byte(255 - i)
+		colorData[i+512] = 0x10
+	}
+	doc := &testDocument{
+		compression: imgRLE,
+		header: Header{
+			Version:   1,
+			Channels:  1,
+			Width:     3,
+			Height:    1,
+			Depth:     8,
+			ColorMode: ColorModeIndexed,
+		},
+		colorData: colorData,
+		resources: testImageResource(imgResTransparencyIndex, []byte{0, 2}),
+		composite: [][]byte{{0, 1, 2}},
+	}
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	img, ok := psd.Image.(image.PalettedImage)
+	require.True(t, ok)
+	require.Equal(t, uint8(1), img.ColorIndexAt(1, 0))
+	require.Equal(t, color.RGBA{R: 0, G: 0xff, B: 0x10, A: 0xff}, img.At(0, 0))
+	require.Equal(t, color.RGBA{R: 1, G: 0xfe, B: 0x10, A: 0xff}, img.At(1, 0))
+	require.Equal(t, color.RGBA{}, img.At(2, 0))
+}
+
+func TestDecode_Bitmap(t *testing.T) {
+	for _, method := range []int{imgRAW, imgRLE} {
+		doc := &testDocument{
+			compression: method,
+			header: Header{
+				Version:   1,
+				Channels:  1,
+				Width:     10,
+				Height:    2,
+				Depth:     1,
+				ColorMode: ColorModeBitmap,
+			},
+			composite: [][]byte{{0x80, 0x40, 0x00, 0xc0}},
+		}
+
+		psd, err := Decode(bytes.NewReader(doc.encode()))
+		require.NoError(t, err)
+
+		img := psd.Image
+		require.Equal(t, image.Rect(0, 0, 10, 2), img.Bounds())
+		require.Equal(t, color.Gray{Y: 0}, img.At(0, 0))
+		require.Equal(t, color.Gray{Y: 0xff}, img.At(1, 0))
+		require.Equal(t, color.Gray{Y: 0}, img.At(9, 0))
+		require.Equal(t, color.Gray{Y: 0xff}, img.At(8, 0))
+		require.Equal(t, color.Gray{Y: 0xff}, img.At(0, 1))
+		require.Equal(t, color.Gray{Y: 0}, img.At(8, 1))
+		require.Equal(t, color.Gray{Y: 0}, img.At(9, 1))
+	}
+}
+
+func TestDecode_Lab(t *testing.T) {
+	doc := &testDocument{
+		compression: imgRLE,
+		header: Header{
+			Version:   1,
+			Channels:  3,
+			Width:     2,
+			Height:    1,
+			Depth:     8,
+			ColorMode: ColorModeLab,
+		},
+		composite: [][]byte{{0xff, 0x00}, {0x80, 0x80}, {0x80, 0x80}},
+	}
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	require.Equal(t, pixelColor.Lab{L: 100}, psd.Image.At(0, 0))
+	require.Equal(t, color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, color.NRGBAModel.Convert(psd.Image.At(0, 0)))
+	require.Equal(t, color.NRGBA{A: 0xff}, color.NRGBAModel.Convert(psd.Image.At(1, 0)))
+}
+
+func TestDecode_Duotone(t *testing.T) {
+	colorData := &bytes.Buffer{}
+	binary.Write(colorData, binary.BigEndian, []uint16{1, 2})
+	for i := 0; i < 4; i++ {
+		binary.Write(colorData, binary.BigEndian, []uint16{0, uint16(i), 0xffff, 0, 0})
+	}
+	for _, name := range []string{"Black", "PANTONE 185 C", "", ""} {
+		ink := make([]byte, 64)
+		ink[0] = byte(len(name))
+		copy(ink[1:], name)
+		colorData.Write(ink)
+	}
+	for i := 0; i < 4; i++ {
+		curve := []int16{0, -1, -1, -1, -1, -1, 500, -1, -1, -1, -1, -1, 1000, 0}
+		binary.Write(colorData, binary.BigEndian, curve)
+	}
+	binary.Write(colorData, binary.BigEndian, uint16(20))
+	for i := 0; i < 11; i++ {
+		binary.Write(colorData, binary.BigEndian, []uint16{0, 0x1000, 0, 0, 0})
+	}
+
+	doc := &testDocument{
+		compression: imgRLE,
+		header: Header{
+			Version:   1,
+			Channels:  1,
+			Width:     2,
+			Height:    1,
+			Depth:     8,
+			ColorMode: ColorModeDuotone,
+		},
+		colorData: colorData.Bytes(),
+		composite: [][]byte{{0x00, 0x80}},
+	}
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	duotone := psd.ColorModeData.Duotone
+	require.NotNil(t, duotone)
+	require.Len(t, duotone.Inks, 2)
+	require.Equal(t, "PANTONE 185 C", duotone.Inks[1].Name)
+	require.Equal(t, &DuotoneColor{Space: 0, Components: [4]int{1, 0xffff, 0, 0}}, duotone.Inks[1].Color)
+	require.Equal(t, []int{0, -1, -1, -1, -1, -1, 500, -1, -1, -1, -1, -1, 1000}, duotone.Inks[0].Curve)
+	require.Equal(t, 20, duotone.DotGain)
+	require.Len(t, duotone.Overprints, 11)
+	require.Equal(t, color.Gray{Y: 0x80}, psd.Image.At(1, 0))
+}
+
+func TestDecode_Multichannel(t *testing.T) {
+	doc := &testDocument{
+		compression: imgRLE,
+		header: Header{
+			Version:   1,
+			Channels:  2,
+			Width:     2,
+			Height:    1,
+			Depth:     8,
+			ColorMode: ColorModeMultichannel,
+		},
+		composite: [][]byte{{0xff, 0x80}, {0x00, 0x80}},
+	}
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	img, ok := psd.Image.(*psdImage.Multichannel8)
+	require.True(t, ok)
+	require.Len(t, img.Planes, 2)
+	require.Equal(t, color.Gray{Y: 0xff}, img.Channel(0).At(0, 0))
+	require.Equal(t, color.Gray{Y: 0x00}, img.Channel(1).At(0, 0))
+	require.Equal(t, color.Gray{Y: 0x00}, img.At(0, 0))
+	require.Equal(t, color.Gray{Y: 0x40}, img.At(1, 0))
+}
+
+func TestDecode_LayerMask(t *testing.T) {
+	mask := &bytes.Buffer{}
+	binary.Write(mask, binary.BigEndian, []int32{-1, 0, 1, 2}) // top, left, bottom, right
+	mask.Write([]byte{0xff, 0, 0, 0})
+
+	doc := newTestRGBDocument(1)
+	layer := doc.layers[0]
+	layer.mask = mask.Bytes()
+	layer.maskRect = image.Rect(0, -1, 2, 1)
+	layer.channels[-2] = []byte{0x00, 0x10, 0x20, 0x30}
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	img := psd.Layers[0].MaskImage
+	require.NotNil(t, img)
+	require.Nil(t, psd.Layers[0].RealMaskImage)
+	require.Equal(t, image.Rect(0, -1, 2, 1), img.Bounds())
+	require.Equal(t, color.Gray{Y: 0x10}, img.At(1, -1))
+	require.Equal(t, color.Gray{Y: 0x30}, img.At(1, 0))
+	require.Equal(t, color.Gray{Y: 0xff}, img.At(2, 2))
+
+	// the layer pixels are still decoded after the mask
+	require.Equal(t, color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}, psd.Layers[0].Image.At(2, 2))
+}
+
+func TestDecode_MaskParameters(t *testing.T) {
+	mask := &bytes.Buffer{}
+	binary.Write(mask, binary.BigEndian, []int32{0, 0, 1, 2})
+	mask.Write([]byte{0x00, 1<<1 | 1<<4})
+	mask.Write([]byte{1<<0 | 1<<3, 0x80})
+	binary.Write(mask, binary.BigEndian, 2.5)
+	mask.Write([]byte{0x00, 0xff})
+	binary.Write(mask, binary.BigEndian, []int32{0, 0, 1, 1})
+
+	doc := newTestRGBDocument(1)
+	doc.layers[0].mask = mask.Bytes()
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	m := psd.Layers[0].Mask
+	require.False(t, m.PositionRelative)
+	require.True(t, m.Disabled)
+	require.False(t, m.Invert)
+	require.False(t, m.FromRendering)
+	require.True(t, m.HasParameters)
+	require.Equal(t, 0x80, *m.Parameters.UserMaskDensity)
+	require.Nil(t, m.Parameters.UserMaskFeather)
+	require.Nil(t, m.Parameters.VectorMaskDensity)
+	require.Equal(t, 2.5, *m.Parameters.VectorMaskFeather)
+	require.Equal(t, byte(0xff), *m.RealBackground)
+	require.Equal(t, image.Rect(0, 0, 1, 1), *m.RectEnclosingMask)
+}
+
+func testAdditionalInfo(key string, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.Write(layerSig)
+	buf.WriteString(key)
+	binary.Write(buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+func testSectionDivider(typ int, blendMode string) []byte {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint32(typ))
+	if blendMode != "" {
+		buf.Write(layerSig)
+		buf.WriteString(blendMode)
+	}
+	return testAdditionalInfo("lsct", buf.Bytes())
+}
+
+func TestDecode_LayerTree(t *testing.T) {
+	doc := newTestRGBDocument(1)
+	doc.layers = []*testLayer{
+		{extra: testSectionDivider(additional.SectionDividerBounding, "")},
+		{extra: testSectionDivider(additional.SectionDividerBounding, "")},
+		{},
+		{extra: testSectionDivider(additional.SectionDividerClosedFolder, "norm")},
+		{},
+		{extra: testSectionDivider(additional.SectionDividerOpenFolder, "pass")},
+		{},
+	}
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+	require.Len(t, psd.Layers, 7)
+
+	layers := psd.Layers
+	root := psd.Root
+	require.True(t, root.Group)
+	require.Equal(t, []*Layer{layers[5], layers[6]}, root.Children)
+
+	outer := layers[5]
+	require.True(t, outer.Group)
+	require.True(t, outer.Open)
+	require.Equal(t, BlendModeKey("pass"), outer.BlendModeKey)
+	require.Equal(t, root, outer.Parent)
+	require.Equal(t, []*Layer{layers[3], layers[4]}, outer.Children)
+
+	inner := layers[3]
+	require.True(t, inner.Group)
+	require.False(t, inner.Open)
+	require.Equal(t, outer, inner.Parent)
+	require.Equal(t, []*Layer{layers[2]}, inner.Children)
+	require.Equal(t, inner, layers[2].Parent)
+
+	require.True(t, layers[0].IsSectionBounding())
+	require.Nil(t, layers[0].Parent)
+}
+
+func TestDecode_LayerAdditionalInfo(t *testing.T) {
+	name := &bytes.Buffer{}
+	binary.Write(name, binary.BigEndian, uint32(2))
+	binary.Write(name, binary.BigEndian, []uint16{'a', 'b'})
+
+	extra := &bytes.Buffer{}
+	extra.Write(testAdditionalInfo("lyid", []byte{0, 0, 0, 42}))
+	extra.Write(testAdditionalInfo("luni", name.Bytes()))
+	extra.Write(testAdditionalInfo("lspf", []byte{0, 0, 0, 4}))
+	extra.Write(testAdditionalInfo("curv", []byte{0, 0, 1, 0, 0, 0, 1, 0, 2, 0, 0, 0, 0, 0, 255, 0, 255}))
+
+	doc := newTestRGBDocument(1)
+	doc.layers[0].extra = extra.Bytes()
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+	require.Len(t, psd.Layers, 1)
+
+	layer := psd.Layers[0]
+	require.Equal(t, 42, layer.ID)
+	require.Equal(t, "ab", layer.Name)
+	require.Equal(t, &additional.Locked{Position: true}, layer.Locked())
+	require.Nil(t, layer.Artboard())
+	require.Equal(t, []additional.CurvesPoint{{Output: 0, Input: 0}, {Output: 255, Input: 255}}, layer.Curves().Curves[0].Points)
+	require.Nil(t, layer.Levels())
+	require.Len(t, layer.AdditionalInfos, 4)
+
+	doc.layers[0].extra = testAdditionalInfo("lsct", []byte("\x00\x00\x00\x01xxxxnorm"))
+	_, err = Decode(bytes.NewReader(doc.encode()))
+	require.Error(t, err)
+}
+
+func TestDecode_RegisteredAdditionalInfo(t *testing.T) {
+	additional.Register("tst!", func(buf []byte) (interface{}, error) {
+		return len(buf), nil
+	})
+	defer additional.Register("tst!", nil)
+
+	extra := &bytes.Buffer{}
+	extra.Write(testAdditionalInfo("tst!", []byte{1, 2, 3, 4}))
+	extra.Write(testAdditionalInfo("unkn", []byte{5, 6, 7, 8}))
+
+	doc := newTestRGBDocument(1)
+	doc.layers[0].extra = extra.Bytes()
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	layer := psd.Layers[0]
+	require.Equal(t, 4, layer.Info("tst!"))
+	require.Nil(t, layer.Info("unkn"))
+	require.Len(t, layer.AdditionalInfos, 2)
+	require.Equal(t, "unkn", layer.AdditionalInfos[1].Key)
+	require.Equal(t, []byte{5, 6, 7, 8}, layer.AdditionalInfos[1].Data)
+}
+
+func TestDecode_AlphaChannels(t *testing.T) {
+	names := &bytes.Buffer{}
+	for _, name := range []string{"Spot", "Alpha 1"} {
+		binary.Write(names, binary.BigEndian, uint32(len(name)))
+		for _, r := range name {
+			binary.Write(names, binary.BigEndian, uint16(r))
+		}
+	}
+
+	doc := newTestRGBDocument(1)
+	doc.header.Channels = 6
+	doc.mergedAlpha = true
+	doc.resources = testImageResource(imgResUnicodeAlphaNames, names.Bytes())
+	doc.composite = append(doc.composite,
+		bytes.Repeat([]byte{0x80}, 12),
+		bytes.Repeat([]byte{0x11}, 12),
+		bytes.Repeat([]byte{0x22}, 12),
+	)
+
+	psd, err := Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+
+	img, ok := psd.Image.(*psdImage.NRGBA8)
+	require.True(t, ok)
+	require.Equal(t, color.NRGBA{R: 0x40, G: 0x50, B: 0x60, A: 0x80}, img.At(0, 0))
+
+	require.Len(t, psd.AlphaChannels, 2)
+	require.Equal(t, "Spot", psd.AlphaChannels[0].Name)
+	require.Equal(t, color.Gray{Y: 0x11}, color.GrayModel.Convert(psd.AlphaChannels[0].Image.At(1, 1)))
+	require.Equal(t, "Alpha 1", psd.AlphaChannels[1].Name)
+	require.Equal(t, color.Gray{Y: 0x22}, color.GrayModel.Convert(psd.AlphaChannels[1].Image.At(3, 2)))
+
+	// without the negative layer count the extra channels are all alpha channels
+	doc.mergedAlpha = false
+	psd, err = Decode(bytes.NewReader(doc.encode()))
+	require.NoError(t, err)
+	_, ok = psd.Image.(*psdImage.NRGB8)
+	require.True(t, ok)
+	require.Len(t, psd.AlphaChannels, 3)
+	require.Equal(t, "", psd.AlphaChannels[2].Name)
+}
+
+func TestDecodeConfig(t *testing.T) {
+	doc := newTestRGBDocument(1)
+	buf := doc.encode()
+
+	cfg, err := DecodeConfig(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, 4, cfg.Width)
+	require.Equal(t, 3, cfg.Height)
+	require.Equal(t, color.NRGBAModel, cfg.ColorModel)
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, "psd", format)
+	require.Equal(t, 4, cfg.Width)
+
+	img, format, err := image.Decode(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, "psd", format)
+	require.Equal(t, image.Rect(0, 0, 4, 3), img.Bounds())
+	require.Equal(t, color.NRGBA{R: 0x40, G: 0x50, B: 0x60, A: 0xff}, img.At(1, 1))
+
+	_, err = DecodeConfig(bytes.NewReader([]byte("8BPX")))
+	require.Error(t, err)
+}
+
+func TestDecodeConfig_ColorModel(t *testing.T) {
+	merged := newTestRGBDocument(2)
+	merged.header.Channels = 4
+	merged.mergedAlpha = true
+	merged.composite = append(merged.composite, bytes.Repeat([]byte{0x80}, 12))
+
+	extra := newTestRGBDocument(1)
+	extra.header.Channels = 4
+	extra.composite = append(extra.composite, bytes.Repeat([]byte{0x80}, 12))
+
+	colorData := make([]byte, 768)
+	for i := range colorData {
+		colorData[i] = byte(i)
+	}
+	indexed := &testDocument{
+		compression: imgRAW,
+		header: Header{
+			Version:   1,
+			Channels:  1,
+			Width:     3,
+			Height:    1,
+			Depth:     8,
+			ColorMode: ColorModeIndexed,
+		},
+		colorData: colorData,
+		resources: testImageResource(imgResTransparencyIndex, []byte{0, 2}),
+		composite: [][]byte{{0, 1, 2}},
+	}
+
+	// merged transparency counted in the layer block of a 16 bit document
+	gray := newTestRGBDocument(1)
+	gray.layerKey = "Lr16"
+	gray.header.Depth = 16
+	gray.header.ColorMode = ColorModeGrayScale
+	gray.header.Channels = 2
+	gray.mergedAlpha = true
+	gray.layers[0].channels = map[int][]byte{-1: make([]byte, 8), 0: make([]byte, 8)}
+	gray.composite = [][]byte{make([]byte, 24), bytes.Repeat([]byte{0x80}, 24)}
+
+	for _, doc := range []*testDocument{newTestRGBDocument(1), merged, extra, indexed, gray} {
+		buf := doc.encode()
+		cfg, err := DecodeConfig(bytes.NewReader(buf))
+		require.NoError(t, err)
+		img, _, err := image.Decode(bytes.NewReader(buf))
+		require.NoError(t, err)
+		require.Equal(t, img.ColorModel(), cfg.ColorModel)
+	}
+}
+
+func TestDecodeWithOptions(t *testing.T) {
+	doc := newTestRGBDocument(1)
+	doc.resources = testImageResource(imgResTransparencyIndex, []byte{0, 1})
+	doc.layers = append(doc.layers, &testLayer{
+		rect:     image.Rect(0, 0, 2, 1),
+		channels: map[int][]byte{0: {1, 2}, 1: {3, 4}, 2: {5, 6}},
+	})
+	buf := doc.encode()
+
+	for _, r := range []io.Reader{bytes.NewReader(buf), bufio.NewReader(bytes.NewReader(buf))} {
+		psd, err := DecodeWithOptions(r, &Options{
+			SkipImageResources: true,
+			SkipComposite:      true,
+			LayerFilter: func(layer *Layer) bool {
+				return layer.Index == 1
+			},
+		})
+		require.NoError(t, err)
+		require.Nil(t, psd.ImageResources)
+		require.Nil(t, psd.Image)
+		require.Len(t, psd.Layers, 2)
+		require.Nil(t, psd.Layers[0].Image)
+		require.NotNil(t, psd.Layers[1].Image)
+	}
+
+	psd, err := DecodeWithOptions(bytes.NewReader(buf), &Options{SkipLayerImages: true})
+	require.NoError(t, err)
+	require.Len(t, psd.ImageResources, 1)
+	require.Nil(t, psd.Layers[0].Image)
+	require.Nil(t, psd.Layers[1].Image)
+	require.Equal(t, color.NRGBA{R: 0x40, G: 0x50, B: 0x60, A: 0xff}, psd.Image.At(0, 0))
+}
+
+func TestDecode_Workers(t *testing.T) {
+	defaultBatchSize := batchSize
+	defer func() { batchSize = defaultBatchSize }()
+
+	for _, compression := range []int{imgRAW, imgRLE, imgZIPWithPrediction} {
+		doc := newTestRGBDocument(1)
+		doc.compression = compression
+		for i := 0; i < 5; i++ {
+			doc.layers = append(doc.layers, &testLayer{
+				rect: image.Rect(0, i, 3, i+2),
+				channels: map[int][]byte{
+					0: bytes.Repeat([]byte{byte(i)}, 6),
+					1: bytes.Repeat([]byte{byte(i + 1)}, 6),
+					2: bytes.Repeat([]byte{byte(i + 2)}, 6),
+				},
+			})
+		}
+		buf := doc.encode()
+
+		expected, err := Decode(bytes.NewReader(buf))
+		require.NoError(t, err)
+		// one batch, then a batch per layer
+		for _, size := range []int{defaultBatchSize, 1} {
+			batchSize = size
+
+			psd, err := DecodeWithOptions(bytes.NewReader(buf), &Options{Workers: 4})
+			require.NoError(t, err)
+
+			require.Equal(t, expected.Image, psd.Image)
+			require.Len(t, psd.Layers, 6)
+			for i, layer := range psd.Layers {
+				require.Equal(t, expected.Layers[i].Image, layer.Image)
+			}
+			require.Equal(t, color.NRGBA{R: 4, G: 5, B: 6, A: 0xff}, psd.Layers[5].Image.At(2, 5))
+		}
+	}
+}
+
+func TestDecode_FormatError(t *testing.T) {
+	doc := newTestRGBDocument(1)
+	buf := doc.encode()
+	i := bytes.Index(buf, []byte("8BIMnorm"))
+	copy(buf[i:], "XXXX")
+
+	_, err := Decode(bytes.NewReader(buf))
+	require.ErrorIs(t, err, ErrLayerSignature)
+	var fe *FormatError
+	require.ErrorAs(t, err, &fe)
+	require.Equal(t, "layer info", fe.Section)
+	require.Equal(t, 0, fe.Layer)
+	require.Equal(t, "", fe.Key)
+	require.Equal(t, int64(i+16), fe.Offset)
+	require.Equal(t, fmt.Sprintf("psd: invalid layer signature (section=layer info, layer=0, offset=%d)", i+16), err.Error())
+
+	doc.layers[0].extra = testAdditionalInfo("lsct", []byte("\x00\x00\x00\x01xxxxnorm"))
+	_, err = Decode(bytes.NewReader(doc.encode()))
+	require.ErrorAs(t, err, &fe)
+	require.Equal(t, "lsct", fe.Key)
+	require.Equal(t, 0, fe.Layer)
+
+	_, err = Decode(bytes.NewReader(buf[:20]))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.ErrorAs(t, err, &fe)
+	require.Equal(t, "header", fe.Section)
+	require.Equal(t, -1, fe.Layer)
+}
+
+func TestDecode_Lenient(t *testing.T) {
+	lenient := &Options{Lenient: true}
+
+	// zero padding between the blocks
+	extra := &bytes.Buffer{}
+	extra.Write(testAdditionalInfo("lyid", []byte{0, 0, 0, 7}))
+	extra.Write([]byte{0, 0})
+	extra.Write(testAdditionalInfo("lspf", []byte{0, 0, 0, 4}))
+	doc := newTestRGBDocument(1)
+	doc.layers[0].extra = extra.Bytes()
+
+	_, err := Decode(bytes.NewReader(doc.encode()))
+	require.Error(t, err)
+	psd, err := DecodeWithOptions(bytes.NewReader(doc.encode()), lenient)
+	require.NoError(t, err)
+	require.Empty(t, psd.Warnings)
+	require.Equal(t, 7, psd.Layers[0].ID)
+	require.NotNil(t, psd.Layers[0].Locked())
+
+	// broken sub-parser and broken block
+	extra.Reset()
+	extra.Write(testAdditionalInfo("lsct", []byte("\x00\x00\x00\x01xxxxnorm")))
+	extra.Write([]byte("garbage block"))
+	mask := &bytes.Buffer{}
+	binary.Write(mask, binary.BigEndian, []int32{0, 0, 1, 2})
+	mask.Write([]byte{0x80, 0, 0, 0}) // invalid default color
+	doc.layers[0].extra = extra.Bytes()
+	doc.layers[0].mask = mask.Bytes()
+	doc.layers[0].maskRect = image.Rect(0, 0, 2, 1)
+	doc.layers[0].channels[-2] = []byte{1, 2}
+
+	psd, err = DecodeWithOptions(bytes.NewReader(doc.encode()), lenient)
+	require.NoError(t, err)
+	require.Len(t, psd.Warnings, 3)
+	var fe *FormatError
+	require.ErrorAs(t, psd.Warnings[0], &fe)
+	require.Equal(t, 0, fe.Layer)
+	require.ErrorAs(t, psd.Warnings[1], &fe)
+	require.Equal(t, "lsct", fe.Key)
+	require.Len(t, psd.Layers[0].AdditionalInfos, 1)
+	require.Nil(t, psd.Layers[0].Mask)
+	require.Nil(t, psd.Layers[0].MaskImage)
+	require.Equal(t, color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}, psd.Layers[0].Image.At(2, 2))
+	require.Equal(t, color.NRGBA{R: 0x40, G: 0x50, B: 0x60, A: 0xff}, psd.Image.At(0, 0))
+
+	psd, err = DecodeWithOptions(bytes.NewReader(doc.encode()), &Options{Lenient: true, SkipComposite: true})
+	require.NoError(t, err)
+	require.Len(t, psd.Warnings, 3)
+	require.Nil(t, psd.Image)
+
+	// short channel image data and truncated composite
+	doc = newTestRGBDocument(1)
+	doc.compression = imgRAW
+	doc.layers[0].channels[1] = []byte{1, 2}
+	buf := doc.encode()
+	_, err = Decode(bytes.NewReader(buf))
+	require.Error(t, err)
+	psd, err = DecodeWithOptions(bytes.NewReader(buf[:len(buf)-4]), lenient)
+	require.NoError(t, err)
+	require.Len(t, psd.Warnings, 2)
+	require.Nil(t, psd.Layers[0].Image)
+	require.Nil(t, psd.Image)
+
+	// broken blending ranges skip to the end of their record
+	doc = newTestRGBDocument(1)
+	doc.layers[0].extra = testAdditionalInfo("lyid", []byte{0, 0, 0, 7})
+	doc.layers = append(doc.layers, &testLayer{
+		rect:     image.Rect(0, 0, 1, 1),
+		channels: map[int][]byte{0: {1}, 1: {2}, 2: {3}},
+		extra:    testAdditionalInfo("lyid", []byte{0, 0, 0, 8}),
+	})
+	buf = doc.encode()
+	i := bytes.Index(buf, []byte("8BIMnorm"))
+	binary.BigEndian.PutUint32(buf[i+20:], 4)
+	_, err = Decode(bytes.NewReader(buf))
+	require.Error(t, err)
+	psd, err = DecodeWithOptions(bytes.NewReader(buf), lenient)
+	require.NoError(t, err)
+	require.Len(t, psd.Warnings, 1)
+	require.ErrorAs(t, psd.Warnings[0], &fe)
+	require.Equal(t, 0, fe.Layer)
+	require.Len(t, psd.Layers, 2)
+	require.Equal(t, 0, psd.Layers[0].ID)
+	require.Equal(t, 8, psd.Layers[1].ID)
+	require.Equal(t, color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}, psd.Layers[0].Image.At(2, 2))
+	require.Equal(t, color.NRGBA{R: 1, G: 2, B: 3, A: 0xff}, psd.Layers[1].Image.At(0, 0))
+
+	// the header is still fatal
+	_, err = DecodeWithOptions(bytes.NewReader(newTestRGBDocument(3).encode()), lenient)
+	require.ErrorIs(t, err, ErrHeaderVersion)
+}
+
+func TestDecode_DeclaredSizes(t *testing.T) {
+	// a few bytes of image data claiming a 20000x20000 composite
+	doc := &testDocument{
+		compression: imgRAW,
+		header: Header{
+			Version:   1,
+			Channels:  3,
+			Width:     20000,
+			Height:    20000,
+			Depth:     8,
+			ColorMode: ColorModeRGB,
+		},
+	}
+	head := doc.encode()
+	head = head[:len(head)-2]
+
+	zip := &bytes.Buffer{}
+	binary.Write(zip, binary.BigEndian, uint16(imgZIPWithOutPrediction))
+	zip.Write(encodeTestZIP(make([]byte, 100), 100, 8, false))
+
+	rle := &bytes.Buffer{}
+	binary.Write(rle, binary.BigEndian, uint16(imgRLE))
+	for i := 0; i < 3*20000; i++ {
+		binary.Write(rle, binary.BigEndian, uint16(2))
+	}
+	for i := 0; i < 3*20000; i++ {
+		rle.Write([]byte{0x81, 0}) // 128 zeros
+	}
+
+	for _, data := range [][]byte{zip.Bytes(), rle.Bytes()} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := Decode(bytes.NewReader(append(head, data...)))
+		runtime.ReadMemStats(&after)
+		require.Error(t, err)
+		require.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(64<<20))
+	}
+
+	// the broken channels leave the composite out in lenient mode
+	psd, err := DecodeWithOptions(bytes.NewReader(append(head, rle.Bytes()...)), &Options{Lenient: true})
+	require.NoError(t, err)
+	require.Nil(t, psd.Image)
+	require.Len(t, psd.Warnings, 3)
+}
+
+func TestDecode_Limits(t *testing.T) {
+	doc := newTestRGBDocument(1)
+	doc.layers = append(doc.layers, doc.layers[0])
+	buf := doc.encode()
+
+	_, err := Decode(bytes.NewReader(buf))
+	require.NoError(t, err)
+
+	for _, limits := range []Limits{
+		{MaxWidth: 3},
+		{MaxHeight: 2},
+		{MaxPixelBytes: 36},
+		{MaxBlockSize: 16},
+		{MaxLayers: 1},
+	} {
+		_, err := DecodeWithOptions(bytes.NewReader(buf), &Options{Limits: limits})
+		require.ErrorIs(t, err, ErrLimitExceeded, "%+v", limits)
+		_, err = DecodeWithOptions(bytes.NewReader(buf), &Options{Limits: limits, Lenient: true})
+		require.ErrorIs(t, err, ErrLimitExceeded, "%+v", limits)
+	}
+
+	doc = newTestRGBDocument(1)
+	doc.header.Channels = 2
+	_, err = Decode(bytes.NewReader(doc.encode()))
+	require.ErrorIs(t, err, ErrHeaderChannels)
+}
+
+func TestDecodeContext(t *testing.T) {
+	doc := newTestRGBDocument(1)
+	doc.layers = append(doc.layers, doc.layers[0])
+	buf := doc.encode()
+
+	var progress []Progress
+	opts := &Options{Progress: func(p Progress) {
+		progress = append(progress, p)
+	}}
+	_, err := DecodeContext(context.Background(), bytes.NewReader(buf), opts)
+	require.NoError(t, err)
+	require.Equal(t, Progress{Section: "header", Layer: -1}, progress[0])
+	sections := []string{}
+	layers := map[int]bool{}
+	for i, p := range progress {
+		if i > 0 {
+			require.GreaterOrEqual(t, p.Read, progress[i-1].Read)
+		}
+		if len(sections) == 0 || sections[len(sections)-1] != p.Section {
+			sections = append(sections, p.Section)
+		}
+		layers[p.Layer] = true
+	}
+	require.Equal(t, []string{"header", "color mode data", "image resources", "layer info", "additional layer information", "image data"}, sections)
+	require.Equal(t, map[int]bool{-1: true, 0: true, 1: true}, layers)
+
+	// canceled while decoding the second layer
+	for _, lenient := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		opts := &Options{Lenient: lenient, Progress: func(p Progress) {
+			if p.Layer == 1 {
+				cancel()
+			}
+		}}
+		_, err = DecodeContext(ctx, bytes.NewReader(buf), opts)
+		require.ErrorIs(t, err, context.Canceled)
+		var fe *FormatError
+		require.ErrorAs(t, err, &fe)
+		require.Equal(t, "layer info", fe.Section)
+		require.Equal(t, 1, fe.Layer)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = DecodeContext(ctx, bytes.NewReader(buf), nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -92,7 +92,7 @@ func Parse(reader *util.Reader) (*Descriptor, error) {
 	if err != nil {
 		return nil, err
 	}
-	num, err := reader.ReadInt()
+	num, err := reader.ReadCount(4)
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +127,7 @@ func parseItem(reader *util.Reader, isKey bool) (*Item, error) {
 
 	switch item.Type {
 	case "obj ":
-		size, err := reader.ReadInt()
+		size, err := reader.ReadCount(4)
 		if err != nil {
 			return nil, err
 		}
@@ -209,7 +209,7 @@ func parseItem(reader *util.Reader, isKey bool) (*Item, error) {
 			return nil, err
 		}
 	case "VlLs":
-		size, err := reader.ReadInt()
+		size, err := reader.ReadCount(4)
 		if err != nil {
 			return nil, err
 		}
@@ -305,7 +305,7 @@ func parseItem(reader *util.Reader, isKey bool) (*Item, error) {
 		}
 		item.Value = data
 	default:
-		panic(fmt.Sprintf("Unknown OSType key [%s] in entity [%s]", item.Key, item.Type))
+		return nil, fmt.Errorf("descriptor: unknown OSType key [%s] in entity [%s]", item.Key, item.Type)
 	}
 
 	return item, nil
@@ -11,6 +11,6 @@ import (
 func TestParser(t *testing.T) {
 	data, err := ioutil.ReadFile("./testdata/descriptor_1")
 	require.NoError(t, err)
-	desc, err := Parser(util.NewReader(data))
+	desc, err := Parse(util.NewReader(data))
 	pp.Println(desc)
 }
@@ -0,0 +1,23 @@
+package descriptor
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	files, _ := filepath.Glob("./testdata/descriptor_*")
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Parse(util.NewReader(data))
+	})
+}
@@ -0,0 +1,92 @@
+package psd
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+)
+
+const (
+	duotoneInks       = 4
+	duotoneOverprints = 11
+	duotoneColorLen   = 10
+	duotoneNameLen    = 64
+	duotoneCurveLen   = 28
+	duotoneDataLen    = 4 + duotoneInks*(duotoneColorLen+duotoneNameLen+duotoneCurveLen) + 2 + duotoneOverprints*duotoneColorLen
+)
+
+// Duotone is the duotone specification stored in the color mode data.
+// Photoshop always stores 4 inks and 11 overprint colors, only the first
+// len(Inks) inks and the overprints of their combinations are meaningful.
+type Duotone struct {
+	Version    int
+	Inks       []*DuotoneInk
+	DotGain    int
+	Overprints []*DuotoneColor
+}
+
+type DuotoneInk struct {
+	Name  string
+	Color *DuotoneColor
+	// Curve is the transfer function: 13 values in 0-1000, -1 for no point.
+	Curve    []int
+	Override bool
+}
+
+// DuotoneColor is a color in the given color space with 4 components.
+type DuotoneColor struct {
+	Space      int
+	Components [4]int
+}
+
+func parseDuotoneColor(buf []byte, offset int) *DuotoneColor {
+	c := &DuotoneColor{Space: int(util.ReadInt16(buf, offset))}
+	for i := range c.Components {
+		c.Components[i] = int(util.ReadUint16(buf, offset+2+i*2))
+	}
+	return c
+}
+
+func parseDuotone(buf []byte) (*Duotone, error) {
+	if len(buf) < duotoneDataLen {
+		return nil, ErrColorModeData
+	}
+
+	duotone := &Duotone{Version: int(util.ReadUint16(buf, 0))}
+	count := int(util.ReadUint16(buf, 2))
+	if count < 1 || count > duotoneInks {
+		return nil, ErrColorModeData
+	}
+
+	colors := 4
+	names := colors + duotoneInks*duotoneColorLen
+	curves := names + duotoneInks*duotoneNameLen
+	duotone.Inks = make([]*DuotoneInk, count)
+	for i := range duotone.Inks {
+		ink := &DuotoneInk{}
+		ink.Color = parseDuotoneColor(buf, colors+i*duotoneColorLen)
+
+		// Pascal string padded to 64 bytes
+		name := buf[names+i*duotoneNameLen : names+(i+1)*duotoneNameLen]
+		if n := int(name[0]); n < duotoneNameLen {
+			ink.Name = string(name[1 : 1+n])
+		}
+
+		curve := curves + i*duotoneCurveLen
+		ink.Curve = make([]int, 13)
+		for j := range ink.Curve {
+			ink.Curve[j] = int(util.ReadInt16(buf, curve+j*2))
+		}
+		ink.Override = util.ReadUint16(buf, curve+26) != 0
+		duotone.Inks[i] = ink
+	}
+
+	read := curves + duotoneInks*duotoneCurveLen
+	duotone.DotGain = int(util.ReadInt16(buf, read))
+	read += 2
+
+	duotone.Overprints = make([]*DuotoneColor, duotoneOverprints)
+	for i := range duotone.Overprints {
+		duotone.Overprints[i] = parseDuotoneColor(buf, read+i*duotoneColorLen)
+	}
+
+	return duotone, nil
+}
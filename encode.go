@@ -319,6 +319,11 @@ func (enc *encoder) composeMask(mask *Mask) (*bytes.Buffer, error) {
 	if _, err := buf.Write([]byte{mask.DefaultColor, mask.Flags}); err != nil {
 		return nil, err
 	}
+	if mask.HasParameters && mask.Parameters != nil {
+		if _, err := buf.Write(composeMaskParameters(mask.Parameters)); err != nil {
+			return nil, err
+		}
+	}
 	if len(mask.Padding) > 0 {
 		if _, err := buf.Write(mask.Padding); err != nil {
 			return nil, err
@@ -343,6 +348,29 @@ func (enc *encoder) composeMask(mask *Mask) (*bytes.Buffer, error) {
 	return buf, nil
 }
 
+func composeMaskParameters(params *MaskParameters) []byte {
+	var flags byte
+	buf := []byte{0}
+	if params.UserMaskDensity != nil {
+		flags |= 1 << 0
+		buf = append(buf, byte(*params.UserMaskDensity))
+	}
+	if params.UserMaskFeather != nil {
+		flags |= 1 << 1
+		buf = append(buf, util.ByteFloat64(*params.UserMaskFeather)...)
+	}
+	if params.VectorMaskDensity != nil {
+		flags |= 1 << 2
+		buf = append(buf, byte(*params.VectorMaskDensity))
+	}
+	if params.VectorMaskFeather != nil {
+		flags |= 1 << 3
+		buf = append(buf, util.ByteFloat64(*params.VectorMaskFeather)...)
+	}
+	buf[0] = flags
+	return buf
+}
+
 func (enc *encoder) composeBlendingRanges(blendingRanges *BlendingRanges) (*bytes.Buffer, error) {
 	buf := &bytes.Buffer{}
 	if blendingRanges == nil {
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"regexp"
 	"strconv"
 	"unicode/utf16"
 )
 
+var ErrSyntax = errors.New("enginedata: invalid syntax")
+
 var (
 	multiLineArrayStart = regexp.MustCompile(`^\/([a-zA-Z0-9]+) \[$`)
 	property            = regexp.MustCompile(`^\/([a-zA-Z0-9]+)$`)
@@ -65,9 +68,12 @@ func (d *decoder) pushStack(node interface{}) {
 	d.current = node
 }
 
-func (d *decoder) popStack() {
+func (d *decoder) popStack() error {
 	// pop
 	l := len(d.stack) - 1
+	if l < 0 {
+		return ErrSyntax
+	}
 	node := d.stack[l]
 	d.stack = d.stack[:l]
 
@@ -77,27 +83,38 @@ func (d *decoder) popStack() {
 		arr = append(arr, d.current)
 		d.current = arr
 	case object:
-		k := d.popKeyStack()
+		k, err := d.popKeyStack()
+		if err != nil {
+			return err
+		}
 		obj := node.(object)
 		obj[k] = d.current
 		d.current = obj
 	}
+	return nil
 }
 
 func (d *decoder) pushKeyStack(key string) {
 	d.keyStack = append(d.keyStack, key)
 }
 
-func (d *decoder) popKeyStack() string {
+func (d *decoder) popKeyStack() (string, error) {
+	if len(d.keyStack) == 0 {
+		return "", ErrSyntax
+	}
 	key := d.keyStack[len(d.keyStack)-1]
 	d.keyStack = d.keyStack[:len(d.keyStack)-1]
-	return key
+	return key, nil
 }
 
-func (d *decoder) setCurrent(key string, value interface{}) {
-	obj := d.current.(object)
+func (d *decoder) setCurrent(key string, value interface{}) error {
+	obj, ok := d.current.(object)
+	if !ok {
+		return ErrSyntax
+	}
 	obj[key] = value
 	d.current = obj
+	return nil
 }
 
 func (d *decoder) parse(buf []byte) (interface{}, error) {
@@ -106,13 +123,13 @@ func (d *decoder) parse(buf []byte) (interface{}, error) {
 	case bytes.Equal(buf, hashStart):
 		d.pushStack(object{})
 	case bytes.Equal(buf, hashEnd):
-		d.popStack()
+		return nil, d.popStack()
 	case multiLineArrayStart.Match(buf):
 		data := multiLineArrayStart.FindSubmatch(buf)
 		d.pushKeyStack(string(data[1]))
 		d.pushStack(array{})
 	case bytes.Equal(buf, multiLineArrayEnd):
-		d.popStack()
+		return nil, d.popStack()
 	case property.Match(buf):
 		data := property.FindSubmatch(buf)
 		d.pushKeyStack(string(data[1]))
@@ -122,7 +139,7 @@ func (d *decoder) parse(buf []byte) (interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
-		d.setCurrent(string(data[1]), v)
+		return nil, d.setCurrent(string(data[1]), v)
 	case singleLineArray.Match(buf):
 		data := singleLineArray.FindSubmatch(buf)
 		arr := array{}
@@ -158,7 +175,7 @@ func (d *decoder) parse(buf []byte) (interface{}, error) {
 
 func decodeUTF16(buf []byte) string {
 	data := make([]uint16, 0, len(buf)/2)
-	for i := 0; i < len(buf); i += 2 {
+	for i := 0; i+1 < len(buf); i += 2 {
 		data = append(data, binary.BigEndian.Uint16(buf[i:i+2]))
 	}
 	return string(utf16.Decode(data))
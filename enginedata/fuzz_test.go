@@ -0,0 +1,18 @@
+package enginedata
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func FuzzParser(f *testing.F) {
+	data, err := ioutil.ReadFile("./testdata/enginedata")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Parser(data)
+	})
+}
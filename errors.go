@@ -0,0 +1,65 @@
+package psd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// FormatError tells where in the file the decoding failed.
+type FormatError struct {
+	// Section is the name of the section being decoded, e.g. "image resources".
+	Section string
+	// Layer is the index of the layer being decoded, or -1.
+	Layer int
+	// Key is the additional layer information key being decoded, or empty.
+	Key string
+	// Offset is the absolute byte offset reached when the decoding failed.
+	Offset int64
+	Err    error
+}
+
+func (e *FormatError) Error() string {
+	s := []string{"section=" + e.Section}
+	if e.Layer >= 0 {
+		s = append(s, "layer="+strconv.Itoa(e.Layer))
+	}
+	if e.Key != "" {
+		s = append(s, "key="+e.Key)
+	}
+	s = append(s, "offset="+strconv.FormatInt(e.Offset, 10))
+	return e.Err.Error() + " (" + strings.Join(s, ", ") + ")"
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// section names of FormatError
+const (
+	sectionHeader     = "header"
+	sectionColorMode  = "color mode data"
+	sectionResources  = "image resources"
+	sectionLayerInfo  = "layer info"
+	sectionGlobalMask = "global layer mask info"
+	sectionAdditional = "additional layer information"
+	sectionImageData  = "image data"
+)
+
+// formatError wraps err with the current position of the decoder.
+func (dec *decoder) formatError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var fe *FormatError
+	if errors.As(err, &fe) {
+		return err
+	}
+	return &FormatError{
+		Section: dec.section,
+		Layer:   dec.layer,
+		Key:     dec.key,
+		Offset:  int64(dec.read),
+		Err:     err,
+	}
+}
@@ -0,0 +1,45 @@
+package psd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzDecode(f *testing.F) {
+	for _, version := range []int{1, 2} {
+		for _, compression := range []int{imgRAW, imgRLE, imgZIPWithPrediction} {
+			doc := newTestRGBDocument(version)
+			doc.compression = compression
+			doc.layers[0].extra = testSectionDivider(1, "pass")
+			f.Add(doc.encode())
+		}
+	}
+
+	limits := Limits{
+		MaxWidth:      1 << 10,
+		MaxHeight:     1 << 10,
+		MaxPixelBytes: 1 << 24,
+		MaxBlockSize:  1 << 24,
+		MaxLayers:     1 << 8,
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, lenient := range []bool{false, true} {
+			psd, err := DecodeWithOptions(bytes.NewReader(data), &Options{Limits: limits, Lenient: lenient})
+			if err != nil {
+				continue
+			}
+			if psd.Image != nil && !psd.Image.Bounds().Empty() {
+				b := psd.Image.Bounds()
+				psd.Image.At(b.Min.X, b.Min.Y)
+				psd.Image.At(b.Max.X-1, b.Max.Y-1)
+			}
+			for _, layer := range psd.Layers {
+				if layer.Image != nil && !layer.Image.Bounds().Empty() {
+					b := layer.Image.Bounds()
+					layer.Image.At(b.Min.X, b.Min.Y)
+					layer.Image.At(b.Max.X-1, b.Max.Y-1)
+				}
+			}
+		}
+	})
+}
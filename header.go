@@ -74,9 +74,9 @@ func (c ColorMode) String() string {
 
 func (c ColorMode) Channels() int {
 	switch c {
-	case ColorModeBitmap, ColorModeGrayScale, ColorModeIndexed:
+	case ColorModeBitmap, ColorModeGrayScale, ColorModeIndexed, ColorModeDuotone:
 		return 1
-	case ColorModeRGB:
+	case ColorModeRGB, ColorModeLab:
 		return 3
 	case ColorModeCMYK:
 		return 4
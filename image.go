@@ -15,23 +15,56 @@ type Image interface {
 func newImage(colorMode ColorMode, depth, method int, hasAlpha bool) (Image, error) {
 	switch colorMode {
 	case ColorModeBitmap:
-		return newImageRAW()
-	case ColorModeGrayScale:
-		return newImageGrayScale()
+		return newImageBitmap(depth, method)
+	case ColorModeGrayScale, ColorModeDuotone:
+		return newImageGrayScale(depth, method, hasAlpha)
+	case ColorModeIndexed:
+		return newImageIndexed(depth, method)
 	case ColorModeRGB:
 		return newImageRGB(depth, method, hasAlpha)
 	case ColorModeCMYK:
-		return newImageCMYK()
+		return newImageCMYK(depth, method, hasAlpha)
+	case ColorModeMultichannel:
+		return newImageMultichannel(depth, method)
+	case ColorModeLab:
+		return newImageLab(depth, method, hasAlpha)
 	}
 	return nil, nil
 }
 
-func newImageRAW() (Image, error) {
-	return nil, nil
+func newImageBitmap(depth, method int) (Image, error) {
+	if depth != 1 {
+		return nil, fmt.Errorf("psd-image: invalid Bitmap depth %d", depth)
+	}
+	return &psdImage.Bitmap{Compression: method}, nil
 }
 
-func newImageGrayScale() (Image, error) {
-	return nil, nil
+func newImageGrayScale(depth, method int, hasAlpha bool) (Image, error) {
+	switch depth {
+	case 8:
+		if hasAlpha {
+			return &psdImage.GrayA8{Compression: method}, nil
+		}
+		return &psdImage.Gray8{Compression: method}, nil
+	case 16:
+		if hasAlpha {
+			return &psdImage.GrayA16{Compression: method}, nil
+		}
+		return &psdImage.Gray16{Compression: method}, nil
+	case 32:
+		if hasAlpha {
+			return &psdImage.GrayA32{Compression: method}, nil
+		}
+		return &psdImage.Gray32{Compression: method}, nil
+	}
+	return nil, fmt.Errorf("psd-image: invalid Grayscale depth %d", depth)
+}
+
+func newImageIndexed(depth, method int) (Image, error) {
+	if depth != 8 {
+		return nil, fmt.Errorf("psd-image: invalid Indexed depth %d", depth)
+	}
+	return &psdImage.Indexed{Compression: method}, nil
 }
 
 func newImageRGB(depth, method int, hasAlpha bool) (Image, error) {
@@ -55,6 +88,44 @@ func newImageRGB(depth, method int, hasAlpha bool) (Image, error) {
 	return nil, fmt.Errorf("psd-image: invalid RGB depth %d", depth)
 }
 
-func newImageCMYK() (Image, error) {
-	return nil, nil
+func newImageCMYK(depth, method int, hasAlpha bool) (Image, error) {
+	switch depth {
+	case 8:
+		if hasAlpha {
+			return &psdImage.CMYKA8{Compression: method}, nil
+		}
+		return &psdImage.CMYK8{Compression: method}, nil
+	case 16:
+		if hasAlpha {
+			return &psdImage.CMYKA16{Compression: method}, nil
+		}
+		return &psdImage.CMYK16{Compression: method}, nil
+	}
+	return nil, fmt.Errorf("psd-image: invalid CMYK depth %d", depth)
+}
+
+func newImageMultichannel(depth, method int) (Image, error) {
+	switch depth {
+	case 8:
+		return &psdImage.Multichannel8{Compression: method}, nil
+	case 16:
+		return &psdImage.Multichannel16{Compression: method}, nil
+	}
+	return nil, fmt.Errorf("psd-image: invalid Multichannel depth %d", depth)
+}
+
+func newImageLab(depth, method int, hasAlpha bool) (Image, error) {
+	switch depth {
+	case 8:
+		if hasAlpha {
+			return &psdImage.LabA8{Compression: method}, nil
+		}
+		return &psdImage.Lab8{Compression: method}, nil
+	case 16:
+		if hasAlpha {
+			return &psdImage.LabA16{Compression: method}, nil
+		}
+		return &psdImage.Lab16{Compression: method}, nil
+	}
+	return nil, fmt.Errorf("psd-image: invalid Lab depth %d", depth)
 }
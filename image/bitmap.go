@@ -0,0 +1,46 @@
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+var bitmapPalette = color.Palette{
+	color.Gray{Y: 0xff},
+	color.Gray{Y: 0x00},
+}
+
+// Bitmap is a 1 bit image. Rows are packed MSB first and padded to a byte,
+// and a set bit means black. It implements image.PalettedImage.
+type Bitmap struct {
+	Rect        image.Rectangle
+	Pix         []byte
+	Compression int
+}
+
+func (p *Bitmap) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Bitmap) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Pix = src[0]
+}
+
+func (p *Bitmap) ColorModel() color.Model {
+	return bitmapPalette
+}
+
+func (p *Bitmap) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Bitmap) At(x, y int) color.Color {
+	return bitmapPalette[p.ColorIndexAt(x, y)]
+}
+
+func (p *Bitmap) ColorIndexAt(x, y int) uint8 {
+	x -= p.Rect.Min.X
+	pos := (y-p.Rect.Min.Y)*((p.Rect.Dx()+7)>>3) + x>>3
+	return (p.Pix[pos] >> uint(7-x&7)) & 1
+}
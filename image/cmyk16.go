@@ -0,0 +1,50 @@
+package image
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+// CMYK16 holds 16 bit CMYK channels as stored by Photoshop,
+// where 0 means 100% ink and 65535 means no ink.
+type CMYK16 struct {
+	Rect        image.Rectangle
+	C           []byte
+	M           []byte
+	Y           []byte
+	K           []byte
+	Compression int
+}
+
+func (p *CMYK16) CompressionType() int {
+	return p.Compression
+}
+
+func (p *CMYK16) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.C = src[0]
+	p.M = src[1]
+	p.Y = src[2]
+	p.K = src[3]
+}
+
+func (p *CMYK16) ColorModel() color.Model {
+	return pixelColor.CMYK64Model
+}
+
+func (p *CMYK16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *CMYK16) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 1
+	return pixelColor.CMYK64{
+		C: 0xffff - util.ReadUint16(p.C, pos),
+		M: 0xffff - util.ReadUint16(p.M, pos),
+		Y: 0xffff - util.ReadUint16(p.Y, pos),
+		K: 0xffff - util.ReadUint16(p.K, pos),
+	}
+}
@@ -0,0 +1,47 @@
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+// CMYK8 holds 8 bit CMYK channels as stored by Photoshop,
+// where 0 means 100% ink and 255 means no ink.
+type CMYK8 struct {
+	Rect        image.Rectangle
+	C           []byte
+	M           []byte
+	Y           []byte
+	K           []byte
+	Compression int
+}
+
+func (p *CMYK8) CompressionType() int {
+	return p.Compression
+}
+
+func (p *CMYK8) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.C = src[0]
+	p.M = src[1]
+	p.Y = src[2]
+	p.K = src[3]
+}
+
+func (p *CMYK8) ColorModel() color.Model {
+	return color.CMYKModel
+}
+
+func (p *CMYK8) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *CMYK8) At(x, y int) color.Color {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	return color.CMYK{
+		C: 0xff - p.C[pos],
+		M: 0xff - p.M[pos],
+		Y: 0xff - p.Y[pos],
+		K: 0xff - p.K[pos],
+	}
+}
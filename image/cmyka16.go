@@ -0,0 +1,53 @@
+package image
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+// CMYKA16 holds 16 bit CMYK channels with transparency.
+// The ink values are inverted as in CMYK16, the alpha is not.
+type CMYKA16 struct {
+	Rect        image.Rectangle
+	C           []byte
+	M           []byte
+	Y           []byte
+	K           []byte
+	A           []byte
+	Compression int
+}
+
+func (p *CMYKA16) CompressionType() int {
+	return p.Compression
+}
+
+func (p *CMYKA16) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.C = src[0]
+	p.M = src[1]
+	p.Y = src[2]
+	p.K = src[3]
+	p.A = src[4]
+}
+
+func (p *CMYKA16) ColorModel() color.Model {
+	return pixelColor.NCMYKA80Model
+}
+
+func (p *CMYKA16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *CMYKA16) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 1
+	return pixelColor.NCMYKA80{
+		C: 0xffff - util.ReadUint16(p.C, pos),
+		M: 0xffff - util.ReadUint16(p.M, pos),
+		Y: 0xffff - util.ReadUint16(p.Y, pos),
+		K: 0xffff - util.ReadUint16(p.K, pos),
+		A: util.ReadUint16(p.A, pos),
+	}
+}
@@ -1 +1,52 @@
 package image
+
+import (
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+// CMYKA8 holds 8 bit CMYK channels with transparency.
+// The ink values are inverted as in CMYK8, the alpha is not.
+type CMYKA8 struct {
+	Rect        image.Rectangle
+	C           []byte
+	M           []byte
+	Y           []byte
+	K           []byte
+	A           []byte
+	Compression int
+}
+
+func (p *CMYKA8) CompressionType() int {
+	return p.Compression
+}
+
+func (p *CMYKA8) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.C = src[0]
+	p.M = src[1]
+	p.Y = src[2]
+	p.K = src[3]
+	p.A = src[4]
+}
+
+func (p *CMYKA8) ColorModel() color.Model {
+	return pixelColor.NCMYKAModel
+}
+
+func (p *CMYKA8) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *CMYKA8) At(x, y int) color.Color {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	return pixelColor.NCMYKA{
+		C: 0xff - p.C[pos],
+		M: 0xff - p.M[pos],
+		Y: 0xff - p.Y[pos],
+		K: 0xff - p.K[pos],
+		A: p.A[pos],
+	}
+}
@@ -0,0 +1,107 @@
+package color
+
+import (
+	"image/color"
+)
+
+func rgbToCMYK64(r, g, b uint32) (uint16, uint16, uint16, uint16) {
+	w := r
+	if w < g {
+		w = g
+	}
+	if w < b {
+		w = b
+	}
+	if w == 0 {
+		return 0, 0, 0, 0xffff
+	}
+	c := (w - r) * 0xffff / w
+	m := (w - g) * 0xffff / w
+	y := (w - b) * 0xffff / w
+	return uint16(c), uint16(m), uint16(y), uint16(0xffff - w)
+}
+
+func cmyk64ToRGB(c, m, y, k uint32) (uint32, uint32, uint32) {
+	w := 0xffff - k
+	r := (0xffff - c) * w / 0xffff
+	g := (0xffff - m) * w / 0xffff
+	b := (0xffff - y) * w / 0xffff
+	return r, g, b
+}
+
+// CMYK64 represents a fully opaque 16 bit CMYK color.
+type CMYK64 struct {
+	C, M, Y, K uint16
+}
+
+func (c CMYK64) RGBA() (uint32, uint32, uint32, uint32) {
+	r, g, b := cmyk64ToRGB(uint32(c.C), uint32(c.M), uint32(c.Y), uint32(c.K))
+	return r, g, b, 0xffff
+}
+
+func newCMYK64Model(c color.Color) color.Color {
+	if _, ok := c.(CMYK64); ok {
+		return c
+	}
+	r, g, b, _ := c.RGBA()
+	cc, mm, yy, kk := rgbToCMYK64(r, g, b)
+	return CMYK64{C: cc, M: mm, Y: yy, K: kk}
+}
+
+// NCMYKA represents a non-alpha-premultiplied 8 bit CMYK color with transparency.
+type NCMYKA struct {
+	C, M, Y, K, A uint8
+}
+
+func (c NCMYKA) RGBA() (uint32, uint32, uint32, uint32) {
+	r, g, b := cmyk64ToRGB(uint32(c.C)*0x101, uint32(c.M)*0x101, uint32(c.Y)*0x101, uint32(c.K)*0x101)
+	a := uint32(c.A) * 0x101
+	return r * a / 0xffff, g * a / 0xffff, b * a / 0xffff, a
+}
+
+func newNCMYKAModel(c color.Color) color.Color {
+	if _, ok := c.(NCMYKA); ok {
+		return c
+	}
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return NCMYKA{}
+	}
+	cc, mm, yy, kk := rgbToCMYK64(r*0xffff/a, g*0xffff/a, b*0xffff/a)
+	return NCMYKA{
+		C: uint8(cc >> 8),
+		M: uint8(mm >> 8),
+		Y: uint8(yy >> 8),
+		K: uint8(kk >> 8),
+		A: uint8(a >> 8),
+	}
+}
+
+// NCMYKA80 represents a non-alpha-premultiplied 16 bit CMYK color with transparency.
+type NCMYKA80 struct {
+	C, M, Y, K, A uint16
+}
+
+func (c NCMYKA80) RGBA() (uint32, uint32, uint32, uint32) {
+	r, g, b := cmyk64ToRGB(uint32(c.C), uint32(c.M), uint32(c.Y), uint32(c.K))
+	a := uint32(c.A)
+	return r * a / 0xffff, g * a / 0xffff, b * a / 0xffff, a
+}
+
+func newNCMYKA80Model(c color.Color) color.Color {
+	if _, ok := c.(NCMYKA80); ok {
+		return c
+	}
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return NCMYKA80{}
+	}
+	cc, mm, yy, kk := rgbToCMYK64(r*0xffff/a, g*0xffff/a, b*0xffff/a)
+	return NCMYKA80{C: cc, M: mm, Y: yy, K: kk, A: uint16(a)}
+}
+
+var (
+	CMYK64Model   = color.ModelFunc(newCMYK64Model)
+	NCMYKAModel   = color.ModelFunc(newNCMYKAModel)
+	NCMYKA80Model = color.ModelFunc(newNCMYKA80Model)
+)
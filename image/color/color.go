@@ -65,6 +65,28 @@ func newNRGBA128Model(c color.Color) color.Color {
 	return NRGBA128{R: fr, G: fg, B: fb, A: float32(a) / 0xffff}
 }
 
+type Gray32 struct {
+	Y float32
+}
+
+func (c Gray32) RGBA() (uint32, uint32, uint32, uint32) {
+	const gamma = 1.0 / 2.2
+	y := fromFloat(float64(c.Y), gamma)
+	return y, y, y, 0xffff
+}
+
+func newGray32Model(c color.Color) color.Color {
+	if _, ok := c.(Gray32); ok {
+		return c
+	}
+	r, g, b, _ := c.RGBA()
+	// same coefficients as color.GrayModel
+	y := (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+	const gamma = 2.2
+	return Gray32{Y: float32(toFloat(y, gamma))}
+}
+
 var (
 	NRGBA128Model = color.ModelFunc(newNRGBA128Model)
+	Gray32Model   = color.ModelFunc(newGray32Model)
 )
@@ -0,0 +1,131 @@
+package color
+
+import (
+	"image/color"
+	"math"
+)
+
+// D50 reference white
+const (
+	labWhiteX = 0.96422
+	labWhiteY = 1.0
+	labWhiteZ = 0.82521
+)
+
+const (
+	labEpsilon = 6.0 / 29.0
+)
+
+func labToRGB(l, a, b float64) (uint32, uint32, uint32) {
+	finv := func(t float64) float64 {
+		if t > labEpsilon {
+			return t * t * t
+		}
+		return 3 * labEpsilon * labEpsilon * (t - 4.0/29.0)
+	}
+	fy := (l + 16) / 116
+	fx := fy + a/500
+	fz := fy - b/200
+	x := labWhiteX * finv(fx)
+	y := labWhiteY * finv(fy)
+	z := labWhiteZ * finv(fz)
+
+	// XYZ (D50) to linear sRGB, Bradford adapted
+	lr := 3.1338561*x - 1.6168667*y - 0.4906146*z
+	lg := -0.9787684*x + 1.9161415*y + 0.0334540*z
+	lb := 0.0719453*x - 0.2289914*y + 1.4052427*z
+	return fromLinearSRGB(lr), fromLinearSRGB(lg), fromLinearSRGB(lb)
+}
+
+func rgbToLab(r, g, b uint32) (float64, float64, float64) {
+	lr := toLinearSRGB(r)
+	lg := toLinearSRGB(g)
+	lb := toLinearSRGB(b)
+
+	// linear sRGB to XYZ (D50), Bradford adapted
+	x := (0.4360747*lr + 0.3850649*lg + 0.1430804*lb) / labWhiteX
+	y := (0.2225045*lr + 0.7168786*lg + 0.0606169*lb) / labWhiteY
+	z := (0.0139322*lr + 0.0971045*lg + 0.7141733*lb) / labWhiteZ
+
+	f := func(t float64) float64 {
+		if t > labEpsilon*labEpsilon*labEpsilon {
+			return math.Cbrt(t)
+		}
+		return t/(3*labEpsilon*labEpsilon) + 4.0/29.0
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+func fromLinearSRGB(v float64) uint32 {
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	switch {
+	case v >= 1:
+		return 0xffff
+	case v <= 0:
+		return 0
+	default:
+		return uint32(v*0xffff + 0.5)
+	}
+}
+
+func toLinearSRGB(v uint32) float64 {
+	c := float64(v) / 0xffff
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// Lab represents a fully opaque CIE L*a*b* color with the D50 white point.
+// L is in [0, 100], A and B are roughly in [-128, 127].
+type Lab struct {
+	L, A, B float64
+}
+
+func (c Lab) RGBA() (uint32, uint32, uint32, uint32) {
+	r, g, b := labToRGB(c.L, c.A, c.B)
+	return r, g, b, 0xffff
+}
+
+func newLabModel(c color.Color) color.Color {
+	if _, ok := c.(Lab); ok {
+		return c
+	}
+	r, g, b, _ := c.RGBA()
+	l, aa, bb := rgbToLab(r, g, b)
+	return Lab{L: l, A: aa, B: bb}
+}
+
+// NLabA represents a non-alpha-premultiplied CIE L*a*b* color with transparency.
+type NLabA struct {
+	L, A, B float64
+	Alpha   uint16
+}
+
+func (c NLabA) RGBA() (uint32, uint32, uint32, uint32) {
+	r, g, b := labToRGB(c.L, c.A, c.B)
+	a := uint32(c.Alpha)
+	return r * a / 0xffff, g * a / 0xffff, b * a / 0xffff, a
+}
+
+func newNLabAModel(c color.Color) color.Color {
+	if _, ok := c.(NLabA); ok {
+		return c
+	}
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return NLabA{}
+	}
+	l, aa, bb := rgbToLab(r*0xffff/a, g*0xffff/a, b*0xffff/a)
+	return NLabA{L: l, A: aa, B: bb, Alpha: uint16(a)}
+}
+
+var (
+	LabModel   = color.ModelFunc(newLabModel)
+	NLabAModel = color.ModelFunc(newNLabAModel)
+)
@@ -0,0 +1,31 @@
+package color
+
+import (
+	"github.com/stretchr/testify/assert"
+	"image/color"
+	"testing"
+)
+
+func TestLab_RGBA(t *testing.T) {
+	r, g, b, a := Lab{L: 100}.RGBA()
+	assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff, 0xffff}, []uint32{r, g, b, a})
+
+	r, g, b, a = Lab{L: 0}.RGBA()
+	assert.Equal(t, []uint32{0, 0, 0, 0xffff}, []uint32{r, g, b, a})
+
+	// sRGB red
+	red := color.NRGBAModel.Convert(Lab{L: 54.29, A: 80.80, B: 69.89}).(color.NRGBA)
+	assert.InDelta(t, 0xff, int(red.R), 1)
+	assert.InDelta(t, 0, int(red.G), 1)
+	assert.InDelta(t, 0, int(red.B), 1)
+
+	r, g, b, a = NLabA{L: 100, Alpha: 0x8000}.RGBA()
+	assert.Equal(t, []uint32{0x8000, 0x8000, 0x8000, 0x8000}, []uint32{r, g, b, a})
+}
+
+func TestLabModel(t *testing.T) {
+	lab := LabModel.Convert(color.NRGBA{R: 0xff, A: 0xff}).(Lab)
+	assert.InDelta(t, 54.29, lab.L, 0.1)
+	assert.InDelta(t, 80.80, lab.A, 0.1)
+	assert.InDelta(t, 69.89, lab.B, 0.1)
+}
@@ -0,0 +1,37 @@
+package image
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"image"
+	"image/color"
+)
+
+type Gray16 struct {
+	Rect        image.Rectangle
+	Y           []byte
+	Compression int
+}
+
+func (p *Gray16) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Gray16) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Y = src[0]
+}
+
+func (p *Gray16) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+func (p *Gray16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Gray16) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 1
+	return color.Gray16{
+		Y: util.ReadUint16(p.Y, pos),
+	}
+}
@@ -0,0 +1,38 @@
+package image
+
+import (
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+type Gray32 struct {
+	Rect        image.Rectangle
+	Y           []byte
+	Compression int
+}
+
+func (p *Gray32) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Gray32) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Y = src[0]
+}
+
+func (p *Gray32) ColorModel() color.Model {
+	return pixelColor.Gray32Model
+}
+
+func (p *Gray32) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Gray32) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 2
+	return pixelColor.Gray32{
+		Y: readFloat32(p.Y, pos),
+	}
+}
@@ -0,0 +1,36 @@
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+type Gray8 struct {
+	Rect        image.Rectangle
+	Y           []byte
+	Compression int
+}
+
+func (p *Gray8) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Gray8) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Y = src[0]
+}
+
+func (p *Gray8) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (p *Gray8) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Gray8) At(x, y int) color.Color {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	return color.Gray{
+		Y: p.Y[pos],
+	}
+}
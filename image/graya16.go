@@ -0,0 +1,43 @@
+package image
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"image"
+	"image/color"
+)
+
+type GrayA16 struct {
+	Rect        image.Rectangle
+	Y           []byte
+	A           []byte
+	Compression int
+}
+
+func (p *GrayA16) CompressionType() int {
+	return p.Compression
+}
+
+func (p *GrayA16) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Y = src[0]
+	p.A = src[1]
+}
+
+func (p *GrayA16) ColorModel() color.Model {
+	return color.NRGBA64Model
+}
+
+func (p *GrayA16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *GrayA16) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 1
+	v := util.ReadUint16(p.Y, pos)
+	return color.NRGBA64{
+		R: v,
+		G: v,
+		B: v,
+		A: util.ReadUint16(p.A, pos),
+	}
+}
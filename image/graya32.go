@@ -0,0 +1,44 @@
+package image
+
+import (
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+type GrayA32 struct {
+	Rect        image.Rectangle
+	Y           []byte
+	A           []byte
+	Compression int
+}
+
+func (p *GrayA32) CompressionType() int {
+	return p.Compression
+}
+
+func (p *GrayA32) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Y = src[0]
+	p.A = src[1]
+}
+
+func (p *GrayA32) ColorModel() color.Model {
+	return pixelColor.NRGBA128Model
+}
+
+func (p *GrayA32) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *GrayA32) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 2
+	v := readFloat32(p.Y, pos)
+	return pixelColor.NRGBA128{
+		R: v,
+		G: v,
+		B: v,
+		A: readFloat32(p.A, pos),
+	}
+}
@@ -0,0 +1,41 @@
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+type GrayA8 struct {
+	Rect        image.Rectangle
+	Y           []byte
+	A           []byte
+	Compression int
+}
+
+func (p *GrayA8) CompressionType() int {
+	return p.Compression
+}
+
+func (p *GrayA8) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Y = src[0]
+	p.A = src[1]
+}
+
+func (p *GrayA8) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+func (p *GrayA8) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *GrayA8) At(x, y int) color.Color {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	return color.NRGBA{
+		R: p.Y[pos],
+		G: p.Y[pos],
+		B: p.Y[pos],
+		A: p.A[pos],
+	}
+}
@@ -0,0 +1,44 @@
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+// Indexed is an 8 bit indexed color image. It implements image.PalettedImage.
+type Indexed struct {
+	Rect        image.Rectangle
+	Pix         []byte
+	Palette     color.Palette
+	Compression int
+}
+
+func (p *Indexed) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Indexed) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Pix = src[0]
+}
+
+func (p *Indexed) ColorModel() color.Model {
+	return p.Palette
+}
+
+func (p *Indexed) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Indexed) At(x, y int) color.Color {
+	i := int(p.ColorIndexAt(x, y))
+	if i >= len(p.Palette) {
+		return color.RGBA{}
+	}
+	return p.Palette[i]
+}
+
+func (p *Indexed) ColorIndexAt(x, y int) uint8 {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	return p.Pix[pos]
+}
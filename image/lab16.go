@@ -0,0 +1,45 @@
+package image
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+type Lab16 struct {
+	Rect        image.Rectangle
+	L           []byte
+	A           []byte
+	B           []byte
+	Compression int
+}
+
+func (p *Lab16) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Lab16) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.L = src[0]
+	p.A = src[1]
+	p.B = src[2]
+}
+
+func (p *Lab16) ColorModel() color.Model {
+	return pixelColor.LabModel
+}
+
+func (p *Lab16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Lab16) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 1
+	return pixelColor.Lab{
+		L: float64(util.ReadUint16(p.L, pos)) * 100 / 0xffff,
+		A: (float64(util.ReadUint16(p.A, pos)) - 0x8000) / 0x100,
+		B: (float64(util.ReadUint16(p.B, pos)) - 0x8000) / 0x100,
+	}
+}
@@ -0,0 +1,44 @@
+package image
+
+import (
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+type Lab8 struct {
+	Rect        image.Rectangle
+	L           []byte
+	A           []byte
+	B           []byte
+	Compression int
+}
+
+func (p *Lab8) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Lab8) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.L = src[0]
+	p.A = src[1]
+	p.B = src[2]
+}
+
+func (p *Lab8) ColorModel() color.Model {
+	return pixelColor.LabModel
+}
+
+func (p *Lab8) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *Lab8) At(x, y int) color.Color {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	return pixelColor.Lab{
+		L: float64(p.L[pos]) * 100 / 0xff,
+		A: float64(p.A[pos]) - 128,
+		B: float64(p.B[pos]) - 128,
+	}
+}
@@ -0,0 +1,48 @@
+package image
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+type LabA16 struct {
+	Rect        image.Rectangle
+	L           []byte
+	A           []byte
+	B           []byte
+	Alpha       []byte
+	Compression int
+}
+
+func (p *LabA16) CompressionType() int {
+	return p.Compression
+}
+
+func (p *LabA16) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.L = src[0]
+	p.A = src[1]
+	p.B = src[2]
+	p.Alpha = src[3]
+}
+
+func (p *LabA16) ColorModel() color.Model {
+	return pixelColor.NLabAModel
+}
+
+func (p *LabA16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *LabA16) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 1
+	return pixelColor.NLabA{
+		L:     float64(util.ReadUint16(p.L, pos)) * 100 / 0xffff,
+		A:     (float64(util.ReadUint16(p.A, pos)) - 0x8000) / 0x100,
+		B:     (float64(util.ReadUint16(p.B, pos)) - 0x8000) / 0x100,
+		Alpha: util.ReadUint16(p.Alpha, pos),
+	}
+}
@@ -0,0 +1,47 @@
+package image
+
+import (
+	"image"
+	"image/color"
+
+	pixelColor "github.com/yu-ichiko/go-psd/image/color"
+)
+
+type LabA8 struct {
+	Rect        image.Rectangle
+	L           []byte
+	A           []byte
+	B           []byte
+	Alpha       []byte
+	Compression int
+}
+
+func (p *LabA8) CompressionType() int {
+	return p.Compression
+}
+
+func (p *LabA8) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.L = src[0]
+	p.A = src[1]
+	p.B = src[2]
+	p.Alpha = src[3]
+}
+
+func (p *LabA8) ColorModel() color.Model {
+	return pixelColor.NLabAModel
+}
+
+func (p *LabA8) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+func (p *LabA8) At(x, y int) color.Color {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	return pixelColor.NLabA{
+		L:     float64(p.L[pos]) * 100 / 0xff,
+		A:     float64(p.A[pos]) - 128,
+		B:     float64(p.B[pos]) - 128,
+		Alpha: uint16(p.Alpha[pos]) * 0x101,
+	}
+}
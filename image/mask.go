@@ -0,0 +1,47 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Mask is a layer mask positioned at its rectangle.
+// Bounds reports the rectangle of the mask data only: the default color covers
+// the rest of the document and is returned by At for points outside Bounds,
+// so callers iterating Bounds never see it.
+type Mask struct {
+	*image.Gray
+	DefaultColor uint8
+}
+
+// NewMask creates a mask from a channel of the given depth,
+// reducing 16 and 32 bit values to 8 bit.
+func NewMask(rect image.Rectangle, depth int, defaultColor uint8, src []byte) *Mask {
+	gray := image.NewGray(rect)
+	switch depth {
+	case 8:
+		copy(gray.Pix, src)
+	case 16:
+		for i := range gray.Pix {
+			gray.Pix[i] = src[i<<1]
+		}
+	case 32:
+		for i := range gray.Pix {
+			v := readFloat32(src, i<<2)
+			gray.Pix[i] = uint8(math.Max(0, math.Min(1, float64(v))) * 0xff)
+		}
+	}
+	return &Mask{Gray: gray, DefaultColor: defaultColor}
+}
+
+func (p *Mask) At(x, y int) color.Color {
+	return p.GrayAt(x, y)
+}
+
+func (p *Mask) GrayAt(x, y int) color.Gray {
+	if !(image.Point{X: x, Y: y}.In(p.Rect)) {
+		return color.Gray{Y: p.DefaultColor}
+	}
+	return p.Gray.GrayAt(x, y)
+}
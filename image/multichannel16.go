@@ -0,0 +1,47 @@
+package image
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"image"
+	"image/color"
+)
+
+// Multichannel16 holds the 16 bit channels of a multichannel image.
+// Each channel is a plane of ink where 0 means 100% ink, like a grayscale image.
+type Multichannel16 struct {
+	Rect        image.Rectangle
+	Planes      [][]byte
+	Compression int
+}
+
+func (p *Multichannel16) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Multichannel16) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Planes = src
+}
+
+// Channel returns the i-th channel as a grayscale image.
+func (p *Multichannel16) Channel(i int) image.Image {
+	return &Gray16{Rect: p.Rect, Y: p.Planes[i], Compression: p.Compression}
+}
+
+func (p *Multichannel16) ColorModel() color.Model {
+	return color.Gray16Model
+}
+
+func (p *Multichannel16) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At returns the channels printed over each other with black ink.
+func (p *Multichannel16) At(x, y int) color.Color {
+	pos := ((y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X) << 1
+	v := uint32(0xffff)
+	for _, plane := range p.Planes {
+		v = v * uint32(util.ReadUint16(plane, pos)) / 0xffff
+	}
+	return color.Gray16{Y: uint16(v)}
+}
@@ -0,0 +1,46 @@
+package image
+
+import (
+	"image"
+	"image/color"
+)
+
+// Multichannel8 holds the 8 bit channels of a multichannel image.
+// Each channel is a plane of ink where 0 means 100% ink, like a grayscale image.
+type Multichannel8 struct {
+	Rect        image.Rectangle
+	Planes      [][]byte
+	Compression int
+}
+
+func (p *Multichannel8) CompressionType() int {
+	return p.Compression
+}
+
+func (p *Multichannel8) Source(rect image.Rectangle, src ...[]byte) {
+	p.Rect = rect
+	p.Planes = src
+}
+
+// Channel returns the i-th channel as a grayscale image.
+func (p *Multichannel8) Channel(i int) image.Image {
+	return &Gray8{Rect: p.Rect, Y: p.Planes[i], Compression: p.Compression}
+}
+
+func (p *Multichannel8) ColorModel() color.Model {
+	return color.GrayModel
+}
+
+func (p *Multichannel8) Bounds() image.Rectangle {
+	return p.Rect
+}
+
+// At returns the channels printed over each other with black ink.
+func (p *Multichannel8) At(x, y int) color.Color {
+	pos := (y-p.Rect.Min.Y)*p.Rect.Dx() + x - p.Rect.Min.X
+	v := uint32(0xff)
+	for _, plane := range p.Planes {
+		v = v * uint32(plane[pos]) / 0xff
+	}
+	return color.Gray{Y: uint8(v)}
+}
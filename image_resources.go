@@ -1,6 +1,11 @@
 package psd
 
-import "errors"
+import (
+	"errors"
+	"strings"
+
+	"github.com/yu-ichiko/go-psd/util"
+)
 
 var (
 	imgResSig = []byte("8BIM")
@@ -13,8 +18,62 @@ const (
 	actualLen           = 4
 )
 
+// image resource IDs
+const (
+	imgResAlphaNames        = 1006
+	imgResUnicodeAlphaNames = 1045
+	imgResTransparencyIndex = 1047
+)
+
 type ImageResourceBlock struct {
 	ID   int
 	Name string
 	Data []byte
 }
+
+func findImageResource(blocks []*ImageResourceBlock, id int) *ImageResourceBlock {
+	for _, block := range blocks {
+		if block.ID == id {
+			return block
+		}
+	}
+	return nil
+}
+
+// transparencyIndex returns the transparent color of an indexed color image or -1.
+func transparencyIndex(blocks []*ImageResourceBlock) int {
+	block := findImageResource(blocks, imgResTransparencyIndex)
+	if block == nil || len(block.Data) < 2 {
+		return -1
+	}
+	return int(util.ReadUint16(block.Data, 0))
+}
+
+// alphaNames returns the names of the alpha channels, preferring the
+// unicode names over the pascal strings.
+func alphaNames(blocks []*ImageResourceBlock) []string {
+	var names []string
+	if block := findImageResource(blocks, imgResUnicodeAlphaNames); block != nil {
+		reader := util.NewReader(block.Data)
+		for {
+			name, err := reader.ReadUnicodeString()
+			if err != nil {
+				break
+			}
+			names = append(names, strings.TrimRight(name, "\x00"))
+		}
+		return names
+	}
+	if block := findImageResource(blocks, imgResAlphaNames); block != nil {
+		for i := 0; i < len(block.Data); {
+			l := int(block.Data[i])
+			i++
+			if i+l > len(block.Data) {
+				break
+			}
+			names = append(names, string(block.Data[i:i+l]))
+			i += l
+		}
+	}
+	return names
+}
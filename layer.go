@@ -1,19 +1,49 @@
 package psd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/yu-ichiko/go-psd/additional"
+	"github.com/yu-ichiko/go-psd/descriptor"
 	"image"
 )
 
 var (
 	layerSig      = []byte("8BIM")
 	additionalSig = []byte("8B64")
+
+	ErrLayerSignature = errors.New("psd: invalid layer signature")
+
+	// keys of additional layer information that have 8 bytes length in PSB
+	additionalLongKeys = map[string]bool{
+		"LMsk": true,
+		"Lr16": true,
+		"Lr32": true,
+		"Layr": true,
+		"Mt16": true,
+		"Mt32": true,
+		"Mtrn": true,
+		"Alph": true,
+		"FMsk": true,
+		"lnk2": true,
+		"FEid": true,
+		"FXid": true,
+		"PxSD": true,
+	}
+
+	// keys of additional layer information holding the layers of 16 and 32 bit
+	// documents, which leave the layer info section empty
+	layerInfoKeys = map[string]bool{
+		"Layr": true,
+		"Lr16": true,
+		"Lr32": true,
+	}
 )
 
 func newLayer() *Layer {
 	return &Layer{
 		AdditionalInfos: []*AdditionalInfo{},
+		info:            map[string]interface{}{},
 	}
 }
 
@@ -27,6 +57,12 @@ type Layer struct {
 	Rect  image.Rectangle
 	Image image.Image
 
+	// MaskImage is the user mask (channel -2) and RealMaskImage is the
+	// real user mask (channel -3) used when a vector mask is also present.
+	// Their bounds are the mask rectangle; At returns the default color outside it.
+	MaskImage     image.Image
+	RealMaskImage image.Image
+
 	Channels     []*Channel
 	BlendModeKey BlendModeKey
 	Opacity      int
@@ -42,58 +78,192 @@ type Layer struct {
 	Mask            *Mask
 	BlendingRanges  *BlendingRanges
 	AdditionalInfos []*AdditionalInfo
+
+	// Group is true for layer groups (folders), Open tells if the folder is expanded.
+	Group    bool
+	Open     bool
+	Parent   *Layer
+	Children []*Layer
+
+	// parsed additional layer information keyed by the info key
+	info map[string]interface{}
 }
 
 func (l *Layer) setRect(top, left, bottom, right int) {
 	l.Rect = image.Rect(left, top, right, bottom)
 }
 
-func (l *Layer) setAdditionalInfo(addInfo *AdditionalInfo) {
+// IsSectionBounding tells if the layer is the hidden "</Layer group>"
+// marker that closes a group.
+func (l *Layer) IsSectionBounding() bool {
+	sd := l.SectionDivider()
+	return sd != nil && sd.Type == additional.SectionDividerBounding
+}
+
+func (l *Layer) setAdditionalInfo(addInfo *AdditionalInfo) error {
+	l.AdditionalInfos = append(l.AdditionalInfos, addInfo)
+
+	parser, ok := additional.Lookup(addInfo.Key)
+	if !ok {
+		return nil
+	}
+	v, err := parser(addInfo.Data)
+	if err != nil {
+		return fmt.Errorf("psd: invalid additional layer information key=%s: %w", addInfo.Key, err)
+	}
+	l.info[addInfo.Key] = v
 
 	switch addInfo.Key {
 	case "lyid":
-		additional.NewLayerID(addInfo.Data)
-	case "lnsr":
-		additional.NewLayerNameSource(addInfo.Data)
+		l.ID, _ = v.(int)
 	case "luni":
-		additional.NewLayerName(addInfo.Data)
-	case "artb":
-		additional.NewArtboard(addInfo.Data)
-	case "fxrp":
-		additional.NewReferencePoint(addInfo.Data)
-	case "GdFl":
-		additional.NewGradientFill(addInfo.Data)
-	case "clbl":
-		additional.NewBlendClippingElements(addInfo.Data)
-	case "infx":
-		additional.NewBlendInteriorElements(addInfo.Data)
-	case "knko":
-		additional.NewKnockoutSetting(addInfo.Data)
-	case "lspf":
-		additional.NewLocked(addInfo.Data)
-	case "lclr":
-		additional.NewSheetColorSetting(addInfo.Data)
-	case "shmd":
-		additional.NewMetadataSetting(addInfo.Data)
-	case "SoCo":
-		additional.NewSolidColorSheetSetting(addInfo.Data)
-	case "TySh":
-		additional.NewTypeToolObjectSetting(addInfo.Data)
-	case "lsct":
-		additional.NewSectionDividerSetting(addInfo.Data)
-	case "vmsk", "vsms":
-		additional.NewVectorMaskSetting(addInfo.Data)
-	case "lyvr":
-		additional.NewLayerVersion(addInfo.Data)
-	case "lfx2":
-		additional.NewObjectEffectsLayerInfo(addInfo.Data)
-	case "lrFX":
-		additional.NewEffectsLayer(addInfo.Data)
-	default:
-		fmt.Println("-->", addInfo.Key)
+		l.Name, _ = v.(string)
 	}
+	return nil
+}
 
-	l.AdditionalInfos = append(l.AdditionalInfos, addInfo)
+// Info returns the parsed value of the additional layer information key,
+// or nil when the key has no registered parser or is not present.
+func (l *Layer) Info(key string) interface{} {
+	return l.info[key]
+}
+
+// NameSource returns the layer name source setting (lnsr).
+func (l *Layer) NameSource() string {
+	v, _ := l.info["lnsr"].(string)
+	return v
+}
+
+// Artboard returns the artboard data (artb) or nil.
+func (l *Layer) Artboard() *additional.Artboard {
+	v, _ := l.info["artb"].(*additional.Artboard)
+	return v
+}
+
+// ReferencePoint returns the effects reference point (fxrp) or nil.
+func (l *Layer) ReferencePoint() *additional.Reference {
+	v, _ := l.info["fxrp"].(*additional.Reference)
+	return v
+}
+
+// GradientFill returns the gradient fill descriptor (GdFl) or nil.
+func (l *Layer) GradientFill() *descriptor.Descriptor {
+	v, _ := l.info["GdFl"].(*descriptor.Descriptor)
+	return v
+}
+
+// BlendClippingElements returns the blend clipped elements setting (clbl).
+func (l *Layer) BlendClippingElements() bool {
+	v, _ := l.info["clbl"].(bool)
+	return v
+}
+
+// BlendInteriorElements returns the blend interior elements setting (infx).
+func (l *Layer) BlendInteriorElements() bool {
+	v, _ := l.info["infx"].(bool)
+	return v
+}
+
+// Knockout returns the knockout setting (knko).
+func (l *Layer) Knockout() bool {
+	v, _ := l.info["knko"].(bool)
+	return v
+}
+
+// Locked returns the protected setting (lspf) or nil.
+func (l *Layer) Locked() *additional.Locked {
+	v, _ := l.info["lspf"].(*additional.Locked)
+	return v
+}
+
+// SheetColor returns the sheet color setting (lclr) or nil.
+func (l *Layer) SheetColor() *additional.SheetColor {
+	v, _ := l.info["lclr"].(*additional.SheetColor)
+	return v
+}
+
+// Metadata returns the metadata setting (shmd).
+func (l *Layer) Metadata() []additional.Metadata {
+	v, _ := l.info["shmd"].([]additional.Metadata)
+	return v
+}
+
+// SolidColor returns the solid color sheet setting (SoCo) or nil.
+func (l *Layer) SolidColor() *additional.SolidColor {
+	v, _ := l.info["SoCo"].(*additional.SolidColor)
+	return v
+}
+
+// TypeTool returns the type tool object setting (TySh) or nil.
+func (l *Layer) TypeTool() *additional.Typetool {
+	v, _ := l.info["TySh"].(*additional.Typetool)
+	return v
+}
+
+// SectionDivider returns the section divider setting (lsct) or nil.
+func (l *Layer) SectionDivider() *additional.SectionDivider {
+	v, _ := l.info["lsct"].(*additional.SectionDivider)
+	return v
+}
+
+// VectorMask returns the vector mask setting (vmsk or vsms) or nil.
+func (l *Layer) VectorMask() *additional.VectorMask {
+	if v, ok := l.info["vmsk"].(*additional.VectorMask); ok {
+		return v
+	}
+	v, _ := l.info["vsms"].(*additional.VectorMask)
+	return v
+}
+
+// Version returns the layer version (lyvr).
+func (l *Layer) Version() int {
+	v, _ := l.info["lyvr"].(int)
+	return v
+}
+
+// ObjectEffects returns the object based effects descriptor (lfx2) or nil.
+func (l *Layer) ObjectEffects() *descriptor.Descriptor {
+	v, _ := l.info["lfx2"].(*descriptor.Descriptor)
+	return v
+}
+
+// Effects returns the legacy effects layer (lrFX) or nil.
+func (l *Layer) Effects() *additional.EffectsLayer {
+	v, _ := l.info["lrFX"].(*additional.EffectsLayer)
+	return v
+}
+
+// Levels returns the levels adjustment (levl) or nil.
+func (l *Layer) Levels() *additional.Levels {
+	v, _ := l.info["levl"].(*additional.Levels)
+	return v
+}
+
+// Curves returns the curves adjustment (curv) or nil.
+func (l *Layer) Curves() *additional.Curves {
+	v, _ := l.info["curv"].(*additional.Curves)
+	return v
+}
+
+// HueSaturation returns the hue/saturation adjustment (hue2, or hue of Photoshop 4.0) or nil.
+func (l *Layer) HueSaturation() *additional.HueSaturation {
+	if v, ok := l.info["hue2"].(*additional.HueSaturation); ok {
+		return v
+	}
+	v, _ := l.info["hue "].(*additional.HueSaturation)
+	return v
+}
+
+// ColorBalance returns the color balance adjustment (blnc) or nil.
+func (l *Layer) ColorBalance() *additional.ColorBalance {
+	v, _ := l.info["blnc"].(*additional.ColorBalance)
+	return v
+}
+
+// SelectiveColor returns the selective color adjustment (selc) or nil.
+func (l *Layer) SelectiveColor() *additional.SelectiveColor {
+	v, _ := l.info["selc"].(*additional.SelectiveColor)
+	return v
 }
 
 type Channel struct {
@@ -183,6 +353,14 @@ type Mask struct {
 	DefaultColor byte
 	Flags        byte
 
+	PositionRelative bool
+	Disabled         bool
+	Invert           bool
+	FromRendering    bool
+	HasParameters    bool
+
+	Parameters *MaskParameters
+
 	Padding []byte
 
 	RealFlags         *byte
@@ -203,6 +381,15 @@ func newMask() *Mask {
 	return &Mask{}
 }
 
+// MaskParameters holds the density (0-255) and feather of the user and
+// vector masks. Values that are not stored in the file are nil.
+type MaskParameters struct {
+	UserMaskDensity   *int
+	UserMaskFeather   *float64
+	VectorMaskDensity *int
+	VectorMaskFeather *float64
+}
+
 type BlendingRanges struct {
 	CompositeGrayBlend *BlendingRangesData
 	Channels           []*BlendingRangesData
@@ -235,3 +422,51 @@ type GlobalLayerMask struct {
 	Kind            int
 	Fillers         int
 }
+
+// newLayerTree builds the group hierarchy from the layers, which are stored
+// from bottom to top. A group starts at its bounding section divider and ends
+// at the folder layer. The children are kept in the same order as the layers
+// and the bounding markers are left out of the tree.
+func newLayerTree(layers []*Layer) *Layer {
+	root := newLayer()
+	root.Index = -1
+	root.Group = true
+	root.Open = true
+
+	stack := [][]*Layer{nil}
+	for _, layer := range layers {
+		sd := layer.SectionDivider()
+		if layer.IsSectionBounding() {
+			stack = append(stack, nil)
+			continue
+		}
+
+		if sd != nil && (sd.Type == additional.SectionDividerOpenFolder || sd.Type == additional.SectionDividerClosedFolder) {
+			layer.Group = true
+			layer.Open = sd.Type == additional.SectionDividerOpenFolder
+			if sd.BlendMode != "" {
+				layer.BlendModeKey = BlendModeKey(sd.BlendMode)
+			}
+			if top := len(stack) - 1; top > 0 {
+				layer.Children = stack[top]
+				stack = stack[:top]
+				for _, child := range layer.Children {
+					child.Parent = layer
+				}
+			}
+		}
+
+		top := len(stack) - 1
+		stack[top] = append(stack[top], layer)
+	}
+
+	// groups without folder layer
+	for _, children := range stack {
+		root.Children = append(root.Children, children...)
+	}
+	for _, child := range root.Children {
+		child.Parent = root
+	}
+
+	return root
+}
@@ -0,0 +1,196 @@
+package psd
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+// OpenReaderAt reads the layer records and indexes the offsets of the
+// image data. The pixels of a layer and of the composite image are decoded
+// on first access of the image, which is a *LazyImage. The images are
+// decoded in strict mode, a broken image is an error of LazyImage.Load.
+func OpenReaderAt(r io.ReaderAt, size int64) (*PSD, error) {
+	dec := newDecoder(io.NewSectionReader(r, 0, size), nil)
+	dec.ra = r
+	dec.size = size
+	return dec.decode()
+}
+
+type lazyLoader struct {
+	once sync.Once
+	load func() error
+	err  error
+}
+
+func (l *lazyLoader) do() error {
+	l.once.Do(func() {
+		l.err = l.load()
+	})
+	return l.err
+}
+
+// LazyImage is an image whose pixels are decoded on first access.
+// It is safe for concurrent use.
+type LazyImage struct {
+	rect   image.Rectangle
+	model  color.Model
+	loader *lazyLoader
+	get    func() image.Image
+}
+
+// Load decodes the image once and returns it.
+func (p *LazyImage) Load() (image.Image, error) {
+	if err := p.loader.do(); err != nil {
+		return nil, err
+	}
+	return p.get(), nil
+}
+
+// ColorModel returns the color model known from the header and the
+// channels, without decoding the image.
+func (p *LazyImage) ColorModel() color.Model {
+	return p.model
+}
+
+func (p *LazyImage) Bounds() image.Rectangle {
+	return p.rect
+}
+
+func (p *LazyImage) At(x, y int) color.Color {
+	img, _ := p.Load()
+	if img == nil {
+		return color.Transparent
+	}
+	return img.At(x, y)
+}
+
+// fork returns a decoder reading from offset, sharing the parsed header.
+func (dec *decoder) fork(offset int64) *decoder {
+	return &decoder{
+		r:           io.NewSectionReader(dec.ra, offset, dec.size-offset),
+		read:        int(offset),
+		ctx:         context.Background(),
+		ra:          dec.ra,
+		size:        dec.size,
+		opts:        dec.opts,
+		header:      dec.header,
+		palette:     dec.palette,
+		mergedAlpha: dec.mergedAlpha,
+		section:     dec.section,
+		layer:       dec.layer,
+		limits:      dec.limits,
+	}
+}
+
+// colorModel returns the color model of the images built by newImage.
+func (dec *decoder) colorModel(hasAlpha bool) color.Model {
+	p, err := dec.newImage(imgRAW, hasAlpha)
+	if err != nil || p == nil {
+		return color.NRGBAModel
+	}
+	return p.ColorModel()
+}
+
+// setLazyLayerImages sets the images of the layer whose channel image data
+// begins at the current offset.
+func (dec *decoder) setLazyLayerImages(layer *Layer) {
+	d := dec.fork(int64(dec.read))
+	tmp := &Layer{Rect: layer.Rect, Channels: layer.Channels, Mask: layer.Mask}
+	loader := &lazyLoader{load: func() error {
+		return d.formatError(d.parseChannelImageData(tmp))
+	}}
+
+	hasAlpha := false
+	for _, channel := range layer.Channels {
+		if channel.ID == -1 && channel.Length != compressionLen {
+			hasAlpha = true
+		}
+	}
+
+	for _, channel := range layer.Channels {
+		if channel.Length == compressionLen {
+			continue
+		}
+		switch {
+		case channel.ID == -3:
+			if layer.Mask != nil && layer.Mask.RectEnclosingMask != nil {
+				layer.RealMaskImage = &LazyImage{
+					rect:   *layer.Mask.RectEnclosingMask,
+					model:  color.GrayModel,
+					loader: loader,
+					get:    func() image.Image { return tmp.RealMaskImage },
+				}
+			}
+		case channel.ID == -2:
+			if layer.Mask != nil {
+				layer.MaskImage = &LazyImage{
+					rect:   layer.Mask.Rect,
+					model:  color.GrayModel,
+					loader: loader,
+					get:    func() image.Image { return tmp.MaskImage },
+				}
+			}
+		case layer.Image == nil:
+			layer.Image = &LazyImage{
+				rect:   layer.Rect,
+				model:  dec.colorModel(hasAlpha),
+				loader: loader,
+				get:    func() image.Image { return tmp.Image },
+			}
+		}
+	}
+}
+
+// setLazyComposite sets the composite image and alpha channels whose
+// image data begins at the current offset.
+func (dec *decoder) setLazyComposite(psd *PSD, names []string) {
+	d := dec.fork(int64(dec.read))
+	var (
+		img    image.Image
+		alphas []*AlphaChannel
+	)
+	loader := &lazyLoader{load: func() error {
+		data, method, err := d.parseImageData()
+		if err != nil {
+			return d.formatError(err)
+		}
+		img, alphas, err = d.newCompositeImage(method, data, names)
+		return d.formatError(err)
+	}}
+
+	rect := dec.header.Rect()
+	n, hasAlpha := dec.compositeChannels()
+	psd.Image = &LazyImage{
+		rect:   rect,
+		model:  dec.colorModel(hasAlpha),
+		loader: loader,
+		get:    func() image.Image { return img },
+	}
+
+	// the alpha channels are grayscale images of the depth
+	alphaModel := color.Model(color.GrayModel)
+	if gray, err := newImageGrayScale(dec.header.Depth, imgRAW, false); err == nil {
+		alphaModel = gray.ColorModel()
+	}
+	for i := 0; i < dec.header.Channels-n; i++ {
+		i := i
+		alpha := &AlphaChannel{Image: &LazyImage{
+			rect:   rect,
+			model:  alphaModel,
+			loader: loader,
+			get: func() image.Image {
+				if i < len(alphas) {
+					return alphas[i].Image
+				}
+				return nil
+			},
+		}}
+		if i < len(names) {
+			alpha.Name = names[i]
+		}
+		psd.AlphaChannels = append(psd.AlphaChannels, alpha)
+	}
+}
@@ -0,0 +1,81 @@
+package psd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+)
+
+type countingReaderAt struct {
+	r     *bytes.Reader
+	mu    sync.Mutex
+	reads int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	c.reads++
+	c.mu.Unlock()
+	return c.r.ReadAt(p, off)
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	mask := &bytes.Buffer{}
+	binary.Write(mask, binary.BigEndian, []int32{1, 1, 2, 3}) // top, left, bottom, right
+	mask.Write([]byte{0xff, 0, 0, 0})
+
+	doc := newTestRGBDocument(2)
+	doc.header.Channels = 4
+	doc.composite = append(doc.composite, bytes.Repeat([]byte{0x11}, 12))
+	doc.layers[0].mask = mask.Bytes()
+	doc.layers[0].maskRect = image.Rect(1, 1, 3, 2)
+	doc.layers[0].channels[-2] = []byte{0x33, 0x44}
+	buf := doc.encode()
+
+	r := &countingReaderAt{r: bytes.NewReader(buf)}
+	psd, err := OpenReaderAt(r, int64(len(buf)))
+	require.NoError(t, err)
+	reads := r.reads
+
+	layer := psd.Layers[0]
+	lazy, ok := layer.Image.(*LazyImage)
+	require.True(t, ok)
+	require.Equal(t, image.Rect(1, 1, 3, 3), lazy.Bounds())
+
+	// the color models are known without decoding
+	decoded, err := Decode(bytes.NewReader(buf))
+	require.NoError(t, err)
+	require.Equal(t, decoded.Layers[0].Image.ColorModel(), lazy.ColorModel())
+	require.Equal(t, decoded.Layers[0].MaskImage.ColorModel(), layer.MaskImage.ColorModel())
+	require.Equal(t, decoded.Image.ColorModel(), psd.Image.ColorModel())
+	require.Equal(t, decoded.AlphaChannels[0].Image.ColorModel(), psd.AlphaChannels[0].Image.ColorModel())
+	require.Equal(t, reads, r.reads)
+
+	colors := make(chan color.Color, 8)
+	for i := 0; i < cap(colors); i++ {
+		go func() {
+			colors <- layer.Image.At(1, 1)
+		}()
+	}
+	for i := 0; i < cap(colors); i++ {
+		require.Equal(t, color.NRGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}, <-colors)
+	}
+	require.Greater(t, r.reads, reads)
+
+	require.Equal(t, image.Rect(1, 1, 3, 2), layer.MaskImage.Bounds())
+	require.Equal(t, color.Gray{Y: 0x44}, layer.MaskImage.At(2, 1))
+
+	require.Equal(t, color.NRGBA{R: 0x40, G: 0x50, B: 0x60, A: 0xff}, psd.Image.At(3, 2))
+	require.Len(t, psd.AlphaChannels, 1)
+	require.Equal(t, color.Gray{Y: 0x11}, color.GrayModel.Convert(psd.AlphaChannels[0].Image.At(0, 0)))
+
+	// truncated files fail on access
+	psd, err = OpenReaderAt(bytes.NewReader(buf[:len(buf)-4]), int64(len(buf)-4))
+	require.NoError(t, err)
+	_, err = psd.Image.(*LazyImage).Load()
+	require.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package psd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is wrapped by the errors of files exceeding the Limits.
+var ErrLimitExceeded = errors.New("psd: limit exceeded")
+
+// DefaultLimits are the limits used for the zero fields of Options.Limits.
+// The dimensions are the maximum of the PSB format.
+var DefaultLimits = Limits{
+	MaxWidth:      300000,
+	MaxHeight:     300000,
+	MaxPixelBytes: 4 << 30,
+	MaxBlockSize:  1 << 30,
+	MaxLayers:     10000,
+}
+
+// Limits bounds what a file can make the decoder allocate.
+type Limits struct {
+	// MaxWidth and MaxHeight bound the image and the layer dimensions.
+	MaxWidth  int
+	MaxHeight int
+	// MaxPixelBytes bounds the total size of the decoded pixel data.
+	MaxPixelBytes int64
+	// MaxBlockSize bounds the size of a single block read into memory.
+	MaxBlockSize int
+	// MaxLayers bounds the number of layers.
+	MaxLayers int
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxWidth <= 0 {
+		l.MaxWidth = DefaultLimits.MaxWidth
+	}
+	if l.MaxHeight <= 0 {
+		l.MaxHeight = DefaultLimits.MaxHeight
+	}
+	if l.MaxPixelBytes <= 0 {
+		l.MaxPixelBytes = DefaultLimits.MaxPixelBytes
+	}
+	if l.MaxBlockSize <= 0 {
+		l.MaxBlockSize = DefaultLimits.MaxBlockSize
+	}
+	if l.MaxLayers <= 0 {
+		l.MaxLayers = DefaultLimits.MaxLayers
+	}
+	return l
+}
+
+func limitError(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrLimitExceeded}, a...)...)
+}
+
+// Options controls which parts of the file are decoded.
+// Skipped sections are seeked over without being decompressed.
+type Options struct {
+	// SkipImageResources skips the image resources section.
+	SkipImageResources bool
+	// SkipLayerImages skips the channel image data of all layers.
+	SkipLayerImages bool
+	// SkipComposite skips the merged image data.
+	SkipComposite bool
+	// LayerFilter reports whether the channel image data of the layer
+	// should be decoded. The layer record is already parsed when called.
+	LayerFilter func(layer *Layer) bool
+	// Workers is the number of goroutines decompressing the channels.
+	// Zero or one decompresses them on the calling goroutine.
+	Workers int
+	// Lenient keeps decoding after a broken block or image data, resynchronising
+	// with the declared lengths. The skipped problems are reported in PSD.Warnings.
+	// Errors in the file header are still fatal.
+	Lenient bool
+	// Limits bounds the sizes declared by the file. The zero fields use DefaultLimits.
+	Limits Limits
+	// Progress is called with the position of the decoder between the
+	// sections, the layers and the channels.
+	Progress func(p Progress)
+}
+
+// Progress is the position of the decoder reported to Options.Progress.
+type Progress struct {
+	// Section is the section being decoded, as in FormatError.
+	Section string
+	// Layer is the index of the layer being decoded, or -1.
+	Layer int
+	// Read is the number of bytes consumed from the file.
+	Read int64
+}
+
+func (o *Options) decodeLayerImage(layer *Layer) bool {
+	if o.SkipLayerImages {
+		return false
+	}
+	return o.LayerFilter == nil || o.LayerFilter(layer)
+}
@@ -0,0 +1,20 @@
+package pathresource
+
+import (
+	"github.com/yu-ichiko/go-psd/util"
+	"testing"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{0, 6, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := util.NewReader(data)
+		for {
+			if _, err := Parse(reader); err != nil {
+				return
+			}
+		}
+	})
+}
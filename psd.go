@@ -14,11 +14,23 @@ const (
 )
 
 type PSD struct {
-	Header          *Header
-	ColorModeData   *ColorModeData
-	ImageResources  []*ImageResourceBlock
-	Layers          []*Layer
+	Header         *Header
+	ColorModeData  *ColorModeData
+	ImageResources []*ImageResourceBlock
+	Layers         []*Layer
+	// Root is a virtual group holding the layer hierarchy
+	Root            *Layer
 	GlobalLayerMask *GlobalLayerMask
 	AdditionalInfos []*AdditionalInfo
 	Image           image.Image
+	// AlphaChannels are the alpha and spot channels stored after
+	// the color channels of the composite image.
+	AlphaChannels []*AlphaChannel
+	// Warnings are the problems skipped with Options.Lenient, as *FormatError.
+	Warnings []error
+}
+
+type AlphaChannel struct {
+	Name  string
+	Image image.Image
 }
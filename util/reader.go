@@ -3,10 +3,16 @@ package util
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"io"
 	"math"
 	"unicode/utf16"
 )
 
+// ErrLength is returned when a length read from the data is negative
+// or exceeds the remaining data.
+var ErrLength = errors.New("util: invalid length")
+
 type Reader struct {
 	buf *bytes.Reader
 	pos int64
@@ -16,6 +22,22 @@ func NewReader(b []byte) *Reader {
 	return &Reader{buf: bytes.NewReader(b), pos: 0}
 }
 
+// check validates that n bytes remain to be read.
+func (r *Reader) check(n int) error {
+	if n < 0 {
+		return ErrLength
+	}
+	if n > r.buf.Len() {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// Len returns the number of bytes not yet read.
+func (r *Reader) Len() int {
+	return r.buf.Len()
+}
+
 func (r *Reader) ReadByte() (byte, error) {
 	var value byte
 	if err := binary.Read(r.buf, binary.BigEndian, &value); err != nil {
@@ -27,6 +49,9 @@ func (r *Reader) ReadByte() (byte, error) {
 
 func (r *Reader) ReadBytes(num interface{}) ([]byte, error) {
 	n := integer(num)
+	if err := r.check(n); err != nil {
+		return nil, err
+	}
 	value := make([]byte, n)
 	if err := binary.Read(r.buf, binary.BigEndian, value); err != nil {
 		return nil, err
@@ -44,6 +69,9 @@ func (r *Reader) ReadBoolean() (bool, error) {
 }
 
 func (r *Reader) ReadString(n int) (string, error) {
+	if err := r.check(n); err != nil {
+		return "", err
+	}
 	value := make([]byte, n)
 	if err := binary.Read(r.buf, binary.BigEndian, &value); err != nil {
 		return "", err
@@ -104,6 +132,19 @@ func (r *Reader) ReadInt() (int, error) {
 	return int(n), nil
 }
 
+// ReadCount reads a 4 byte element count and validates it against the
+// remaining data, given that each element occupies at least size bytes.
+func (r *Reader) ReadCount(size int) (int, error) {
+	n, err := r.ReadInt()
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 || n > r.buf.Len()/size {
+		return 0, ErrLength
+	}
+	return n, nil
+}
+
 func (r *Reader) ReadInt32() (int32, error) {
 	var value int32
 	if err := binary.Read(r.buf, binary.BigEndian, &value); err != nil {
@@ -179,6 +220,9 @@ func (r *Reader) ReadUnicodeString() (string, error) {
 }
 
 func (r *Reader) ReadUnicodeStringLen(num int) (string, error) {
+	if err := r.check(num * 2); err != nil {
+		return "", err
+	}
 	str := make([]uint16, num)
 	for i := range str {
 		if err := binary.Read(r.buf, binary.BigEndian, &str[i]); err != nil {
@@ -203,6 +247,9 @@ func (r *Reader) ReadDynamicString() (string, error) {
 
 func (r *Reader) Skip(n interface{}) error {
 	num := integer(n)
+	if err := r.check(num); err != nil {
+		return err
+	}
 	r.pos += int64(num)
 	if _, err := r.buf.Seek(int64(num), 1); err != nil {
 		return err
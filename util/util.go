@@ -76,6 +76,12 @@ func ByteUint32(n int) []byte {
 	return b
 }
 
+func ByteFloat64(f float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(f))
+	return b
+}
+
 func BytePascalString(str string) ([]byte, int, error) {
 	n := len(str)
 	if n == 0 {
@@ -130,6 +136,13 @@ func GetSize(isPSB bool) int {
 	return 4
 }
 
+func ReadSize(buf []byte, offset int, isPSB bool) int {
+	if isPSB {
+		return int(ReadUint64(buf, offset))
+	}
+	return int(ReadUint32(buf, offset))
+}
+
 func Abs(x int) int {
 	if x < 0 {
 		return -x